@@ -6,7 +6,9 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/MobAI-App/aibridge/internal/bridge"
 	"github.com/MobAI-App/aibridge/internal/config"
@@ -18,14 +20,31 @@ import (
 var (
 	version = "1.0.0"
 
-	flagPort        int
-	flagHost        string
-	flagBusyPattern string
-	flagTimeout     int
-	flagVerbose     bool
-	flagVersion     bool
-	flagParanoid    bool
-	flagInjectDelay int
+	flagPort         int
+	flagHost         string
+	flagBusyPattern  string
+	flagReadyPattern string
+	flagTimeout      int
+	flagVerbose      bool
+	flagVersion      bool
+	flagParanoid     bool
+	flagInjectDelay  int
+	flagScrollback   int
+	flagQueueSize    int
+	flagTTL          int
+	flagPersistQueue bool
+	flagStateDir     string
+	flagBatch        bool
+	flagBatchSep     string
+	flagBatchHeader  string
+	flagIdempotency  int
+	flagPauseKey     string
+	flagPreempt      int
+	flagHeadless     bool
+	flagCols         int
+	flagRows         int
+	flagNoOutput     bool
+	flagOrigins      []string
 )
 
 func main() {
@@ -42,10 +61,27 @@ with a PTY and exposes an HTTP API for external text injection.`,
 	rootCmd.Flags().IntVarP(&flagPort, "port", "p", config.DefaultPort, "HTTP server port")
 	rootCmd.Flags().StringVar(&flagHost, "host", config.DefaultHost, "HTTP server host")
 	rootCmd.Flags().StringVar(&flagBusyPattern, "busy-pattern", "", "Custom busy detection regex pattern")
+	rootCmd.Flags().StringVar(&flagReadyPattern, "ready-pattern", "", "Custom ready (prompt drawn) detection regex pattern")
 	rootCmd.Flags().IntVarP(&flagTimeout, "timeout", "t", config.DefaultTimeout, "Sync injection timeout in seconds")
 	rootCmd.Flags().BoolVarP(&flagVerbose, "verbose", "v", false, "Enable verbose logging")
 	rootCmd.Flags().BoolVar(&flagParanoid, "paranoid", false, "Inject text without hitting Enter")
 	rootCmd.Flags().IntVar(&flagInjectDelay, "inject-delay", config.DefaultInjectDelay, "Delay in ms between text injection and Enter key")
+	rootCmd.Flags().IntVar(&flagScrollback, "scrollback", config.DefaultScrollback, "Size in bytes of the output buffer served by /output")
+	rootCmd.Flags().IntVar(&flagQueueSize, "queue-size", config.DefaultQueueSize, "Maximum number of pending injections")
+	rootCmd.Flags().IntVar(&flagTTL, "ttl", config.DefaultTTL, "Default time in seconds an injection may wait in the queue (0 = forever)")
+	rootCmd.Flags().BoolVar(&flagPersistQueue, "persist-queue", false, "Keep queued injections in a journal that survives restarts")
+	rootCmd.Flags().StringVar(&flagStateDir, "state-dir", config.DefaultStateDir(), "Directory for the queue journal")
+	rootCmd.Flags().BoolVar(&flagBatch, "batch", false, "Type all pending injections together in a single submission")
+	rootCmd.Flags().StringVar(&flagBatchSep, "batch-separator", "", `Text between batched injections, with \n and \t expanded (default: blank line)`)
+	rootCmd.Flags().StringVar(&flagBatchHeader, "batch-header", "", "Line typed before batched injections")
+	rootCmd.Flags().IntVar(&flagIdempotency, "idempotency-window", config.DefaultIdempotency, "Seconds an Idempotency-Key is remembered")
+	rootCmd.Flags().IntVar(&flagPreempt, "preempt-interval", config.DefaultPreempt, "Minimum seconds between two preempting injections interrupting the tool")
+	rootCmd.Flags().StringVar(&flagPauseKey, "pause-key", config.DefaultPauseKey, "Key that pauses and resumes injections from the local terminal (none to disable)")
+	rootCmd.Flags().BoolVar(&flagHeadless, "headless", false, "Run without a controlling terminal; the HTTP API is the only interface")
+	rootCmd.Flags().IntVar(&flagCols, "cols", config.DefaultCols, "Terminal width in headless mode")
+	rootCmd.Flags().IntVar(&flagRows, "rows", config.DefaultRows, "Terminal height in headless mode")
+	rootCmd.Flags().BoolVar(&flagNoOutput, "discard-output", false, "Do not mirror the tool's output to stdout")
+	rootCmd.Flags().StringSliceVar(&flagOrigins, "allow-origin", nil, "Web origin allowed to open /attach besides localhost (repeatable, * for any)")
 	rootCmd.Flags().BoolVar(&flagVersion, "version", false, "Print version and exit")
 
 	rootCmd.PreRun = func(cmd *cobra.Command, args []string) {
@@ -69,16 +105,7 @@ func run(cmd *cobra.Command, args []string) {
 	commandArgs := args[1:]
 
 	toolName := filepath.Base(command)
-
-	var pattern *patterns.Pattern
-	if flagBusyPattern != "" {
-		pattern = &patterns.Pattern{Regex: flagBusyPattern}
-	} else {
-		pattern = patterns.GetPattern(toolName)
-		if pattern == nil {
-			pattern = patterns.DefaultPattern()
-		}
-	}
+	pattern := toolPattern(toolName, flagBusyPattern, flagReadyPattern)
 
 	if flagVerbose {
 		logFile, err := os.OpenFile("/tmp/aibridge.log", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
@@ -89,14 +116,48 @@ func run(cmd *cobra.Command, args []string) {
 		log.SetOutput(logFile)
 		log.Printf("Starting aibridge with command: %s %v", command, commandArgs)
 		log.Printf("Using pattern: %s", pattern.Regex)
+		log.Printf("Using ready pattern: %s", pattern.Ready)
+	}
+
+	pauseKey, err := bridge.ParseHotkey(flagPauseKey)
+	if err != nil {
+		log.Fatalf("Invalid --pause-key: %v", err)
+	}
+
+	var journalPath string
+	if flagPersistQueue {
+		journalPath = filepath.Join(flagStateDir, fmt.Sprintf("queue-%d.jsonl", flagPort))
 	}
 
-	b, err := bridge.New(command, commandArgs, pattern.Regex, flagVerbose, flagParanoid, flagInjectDelay)
+	b, err := bridge.New(command, commandArgs, bridge.Options{
+		BusyPattern:       pattern.Regex,
+		ReadyPattern:      pattern.Ready,
+		Verbose:           flagVerbose,
+		Paranoid:          flagParanoid,
+		InjectDelayMs:     flagInjectDelay,
+		ScrollbackSize:    flagScrollback,
+		QueueCapacity:     flagQueueSize,
+		DefaultTTL:        time.Duration(flagTTL) * time.Second,
+		JournalPath:       journalPath,
+		Batch:             flagBatch,
+		BatchSeparator:    unescape(flagBatchSep),
+		BatchHeader:       unescape(flagBatchHeader),
+		IdempotencyWindow: time.Duration(flagIdempotency) * time.Second,
+		Newline:           pattern.Newline,
+		InterruptKey:      pattern.Interrupt,
+		PreemptInterval:   time.Duration(flagPreempt) * time.Second,
+		Headless:          flagHeadless,
+		Cols:              flagCols,
+		Rows:              flagRows,
+		DiscardOutput:     flagNoOutput,
+		PauseKey:          pauseKey,
+	})
 	if err != nil {
 		log.Fatalf("Failed to create bridge: %v", err)
 	}
 
 	srv := server.New(b, flagHost, flagPort, flagVerbose)
+	srv.AllowOrigins(flagOrigins)
 	go func() {
 		if err := srv.Start(); err != nil {
 			log.Printf("HTTP server error: %v", err)
@@ -125,3 +186,25 @@ func run(cmd *cobra.Command, args []string) {
 	_ = b.Close()
 	srv.GracefulShutdown()
 }
+
+// toolPattern returns the detection patterns for toolName, with the
+// --busy-pattern and --ready-pattern flags overriding the built-in ones when
+// set.
+func toolPattern(toolName, busyPattern, readyPattern string) *patterns.Pattern {
+	pattern := patterns.GetPattern(toolName)
+	if pattern == nil {
+		pattern = patterns.DefaultPattern()
+	}
+	if busyPattern != "" {
+		pattern.Regex = busyPattern
+	}
+	if readyPattern != "" {
+		pattern.Ready = readyPattern
+	}
+	return pattern
+}
+
+// unescape expands the \n and \t escapes that shells pass through literally.
+func unescape(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(s)
+}
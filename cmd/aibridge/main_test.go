@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/MobAI-App/aibridge/internal/bridge"
+)
+
+func TestToolPattern(t *testing.T) {
+	if p := toolPattern("claude", "", ""); p.Regex != "thinking" {
+		t.Errorf("claude busy pattern = %q, want the built-in one", p.Regex)
+	}
+	if p := toolPattern("claude", "working", "^> $"); p.Regex != "working" || p.Ready != "^> $" {
+		t.Errorf("overridden pattern = %+v, want the flag values", p)
+	}
+}
+
+func TestToolPatternUnknownTool(t *testing.T) {
+	p := toolPattern("my-agent", "", "")
+	if p.Regex != "" {
+		t.Fatalf("busy pattern = %q, want none for an unknown tool", p.Regex)
+	}
+
+	d, err := bridge.NewBusyDetector(p.Regex, p.Ready, nil, false)
+	if err != nil {
+		t.Fatalf("NewBusyDetector failed: %v", err)
+	}
+	defer d.Close()
+
+	d.ProcessScreen([]string{"Working on it..."})
+	if d.IsIdle() {
+		t.Error("any output should count as busy for a tool without a pattern")
+	}
+}
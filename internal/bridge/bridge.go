@@ -1,44 +1,197 @@
 package bridge
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/MobAI-App/aibridge/internal/vterm"
+	"github.com/google/uuid"
 )
 
+var errChildExited = errors.New("child process exited")
+
+// DefaultInterruptKey stops the current turn of tools without their own
+// interrupt key.
+const DefaultInterruptKey = "ctrl-c"
+
+// DefaultBatchSeparator goes between the texts of batched injections.
+const DefaultBatchSeparator = "\n\n"
+
+type Options struct {
+	BusyPattern    string
+	ReadyPattern   string
+	Verbose        bool
+	Paranoid       bool
+	InjectDelayMs  int
+	ScrollbackSize int
+	QueueCapacity  int
+	// DefaultTTL is applied to injections enqueued without a TTL. Zero
+	// means they never expire.
+	DefaultTTL time.Duration
+	// JournalPath enables the persistent queue journal. Pending injections
+	// found there are restored on startup.
+	JournalPath string
+	// Batch makes every injection batchable, as if enqueued with
+	// EnqueueOptions.Batch.
+	Batch bool
+	// BatchSeparator goes between batched texts; empty means
+	// DefaultBatchSeparator. BatchHeader, if set, is put on its own line
+	// before them.
+	BatchSeparator string
+	BatchHeader    string
+	// IdempotencyWindow is how long idempotency keys are remembered; zero
+	// means DefaultIdempotencyWindow.
+	IdempotencyWindow time.Duration
+	// Newline is typed for line breaks in injected text when the tool has
+	// not enabled bracketed paste.
+	Newline string
+	// InterruptKey names the key that stops the tool's current turn, as
+	// accepted by EnqueueKeys. Empty means DefaultInterruptKey.
+	InterruptKey string
+	// PreemptInterval is the least time between two preemptions; a
+	// preempting injection enqueued sooner waits for the turn to end
+	// instead. Zero means no limit.
+	PreemptInterval time.Duration
+	// Headless runs the child on a Cols x Rows terminal of its own instead
+	// of the local one; see PTY.SetHeadless.
+	Headless bool
+	Cols     int
+	Rows     int
+	// DiscardOutput stops mirroring the child's output to stdout.
+	DiscardOutput bool
+	// PauseKey toggles Pause and Resume when typed in the local terminal.
+	// Zero disables it.
+	PauseKey byte
+}
+
+type EnqueueOptions struct {
+	// Priority ranges from MinPriority to MaxPriority; higher levels are
+	// injected first.
+	Priority int
+	Sync     bool
+	// Source labels the client that queued the injection.
+	Source string
+	// Key coalesces injections: a pending injection with the same key is
+	// superseded, and the new one takes its place in the queue.
+	Key string
+	// NotBefore keeps the injection queued until the given time.
+	NotBefore time.Time
+	// TTL drops the injection if it is still queued this long after being
+	// enqueued. Zero uses Options.DefaultTTL.
+	TTL time.Duration
+	// IdempotencyKey makes retries of the same request safe: a key seen
+	// within Options.IdempotencyWindow is not queued again.
+	IdempotencyKey string
+	// Batch lets the injection be typed together with the other batchable
+	// injections pending next to it, in a single submission.
+	Batch bool
+	// Preempt interrupts the tool's running turn so the injection does not
+	// wait for it to finish. It has no effect on injections that are not
+	// due yet.
+	Preempt bool
+	// WaitResponse captures the agent's output until it goes idle after
+	// the injection and delivers it on the injection's ResponseChan.
+	WaitResponse bool
+}
+
 type Bridge struct {
 	pty          *PTY
 	queue        *Queue
 	busyDetector *BusyDetector
+	output       *OutputBuffer
+	events       *EventBus
 	startTime    time.Time
 	toolName     string
 	verbose      bool
 	paranoid     bool
+	defaultTTL   time.Duration
+	batch        bool
+	batchSep     string
+	batchHeader  string
+	interruptKey string
+	preemptEvery time.Duration
+	preemptMu    sync.Mutex
+	lastPreempt  time.Time
+	outputMu     sync.Mutex
+	history      *History
+	idempotency  *IdempotencyStore
+	journal      *Journal
+	schedules    *Scheduler
+	activeMu     sync.Mutex
+	active       *activeInjection
+	startTimeout time.Duration
 	mu           sync.RWMutex
 	running      bool
+	paused       bool
 	injectCh     chan struct{}
 	stopCh       chan struct{}
 }
 
-func New(command string, args []string, busyPattern string, verbose, paranoid bool, injectDelayMs int) (*Bridge, error) {
+func New(command string, args []string, opts Options) (*Bridge, error) {
 	b := &Bridge{
-		pty:       NewPTY(command, args, injectDelayMs),
-		queue:     NewQueue(),
-		startTime: time.Now(),
-		toolName:  command,
-		verbose:   verbose,
-		paranoid:  paranoid,
-		injectCh:  make(chan struct{}, 1),
-		stopCh:    make(chan struct{}),
+		pty:          NewPTY(command, args, opts.InjectDelayMs),
+		queue:        NewQueueWithCapacity(opts.QueueCapacity),
+		output:       NewOutputBuffer(opts.ScrollbackSize),
+		events:       NewEventBus(),
+		history:      NewHistory(DefaultHistorySize),
+		idempotency:  NewIdempotencyStore(opts.IdempotencyWindow),
+		startTime:    time.Now(),
+		toolName:     command,
+		verbose:      opts.Verbose,
+		paranoid:     opts.Paranoid,
+		defaultTTL:   opts.DefaultTTL,
+		batch:        opts.Batch,
+		batchSep:     opts.BatchSeparator,
+		batchHeader:  opts.BatchHeader,
+		startTimeout: responseStartTimeout,
+		injectCh:     make(chan struct{}, 1),
+		stopCh:       make(chan struct{}),
 	}
 
-	detector, err := NewBusyDetector(busyPattern, b.triggerInject, verbose)
+	if b.batchSep == "" {
+		b.batchSep = DefaultBatchSeparator
+	}
+	b.interruptKey = opts.InterruptKey
+	if b.interruptKey == "" {
+		b.interruptKey = DefaultInterruptKey
+	}
+	if err := ValidateKeys([]string{b.interruptKey}); err != nil {
+		return nil, fmt.Errorf("invalid interrupt key: %w", err)
+	}
+	b.preemptEvery = opts.PreemptInterval
+	b.schedules = NewScheduler(b.enqueueScheduled)
+	b.pty.SetNewline(opts.Newline)
+	if opts.Headless {
+		if opts.Cols <= 0 || opts.Rows <= 0 {
+			return nil, fmt.Errorf("invalid terminal size %dx%d", opts.Cols, opts.Rows)
+		}
+		b.pty.SetHeadless(opts.Cols, opts.Rows)
+	}
+	if opts.DiscardOutput {
+		b.pty.SetOutput(nil)
+	}
+	if opts.PauseKey != 0 {
+		b.pty.SetHotkey(opts.PauseKey, b.togglePause)
+	}
+
+	detector, err := NewBusyDetector(opts.BusyPattern, opts.ReadyPattern, b.onStateChange, opts.Verbose)
 	if err != nil {
-		return nil, fmt.Errorf("invalid busy pattern: %w", err)
+		return nil, fmt.Errorf("invalid busy or ready pattern: %w", err)
 	}
 	b.busyDetector = detector
 
+	if opts.JournalPath != "" {
+		if err := b.restoreQueue(opts.JournalPath); err != nil {
+			detector.Close()
+			return nil, err
+		}
+	}
+
 	return b, nil
 }
 
@@ -47,9 +200,13 @@ func (b *Bridge) Start() error {
 	b.running = true
 	b.mu.Unlock()
 
-	err := b.pty.Start(func(line string) {
-		b.busyDetector.ProcessLine(line)
-	})
+	// Injections restored from the journal wait for the tool to start up
+	// and settle rather than being typed into a half-drawn screen.
+	if b.queue.Len() > 0 {
+		b.busyDetector.SetBusy()
+	}
+
+	err := b.pty.Start(b.onOutput)
 	if err != nil {
 		return err
 	}
@@ -59,6 +216,58 @@ func (b *Bridge) Start() error {
 	return nil
 }
 
+func (b *Bridge) onOutput(data []byte) {
+	if b.verbose {
+		log.Printf("PTY output: %q", data)
+	}
+	b.outputMu.Lock()
+	_, _ = b.pty.Screen().Write(data)
+	offset := b.output.End()
+	_, _ = b.output.Write(data)
+	b.events.Publish(EventOutput, OutputEvent{Offset: offset, Data: string(data)})
+	b.outputMu.Unlock()
+
+	// Any redraw after the prompt was submitted means the tool is working on
+	// it, even on short turns that never show the busy pattern.
+	changed := b.busyDetector.ProcessScreen(b.pty.Screen().Lines())
+	if changed || b.busyDetector.BusySignal() {
+		b.markResponseStarted()
+	}
+}
+
+// Attach returns the current screen together with a subscription that
+// receives every event published after the snapshot was taken, so a remote
+// viewer can draw the screen and then apply output chunks without gaps or
+// duplicates.
+func (b *Bridge) Attach() (vterm.Snapshot, *Subscription) {
+	b.outputMu.Lock()
+	defer b.outputMu.Unlock()
+
+	snap := b.pty.Screen().Snapshot()
+	_, sub := b.events.Subscribe(^uint64(0))
+	return snap, sub
+}
+
+func (b *Bridge) WriteInput(data []byte) error {
+	_, err := b.pty.Write(data)
+	return err
+}
+
+func (b *Bridge) Resize(cols, rows int) error {
+	if cols <= 0 || rows <= 0 {
+		return fmt.Errorf("invalid terminal size %dx%d", cols, rows)
+	}
+	return b.pty.Resize(cols, rows)
+}
+
+func (b *Bridge) onStateChange(idle bool) {
+	b.events.Publish(EventState, StateEvent{Idle: idle})
+	if idle {
+		b.finishActive(nil)
+		b.triggerInject()
+	}
+}
+
 func (b *Bridge) triggerInject() {
 	select {
 	case b.injectCh <- struct{}{}:
@@ -67,45 +276,474 @@ func (b *Bridge) triggerInject() {
 }
 
 func (b *Bridge) injectionLoop() {
+	// due wakes the loop when a scheduled injection becomes due, even if
+	// the tool stays idle and nothing else triggers an injection.
+	due := time.NewTimer(0)
+	defer due.Stop()
+
 	for {
+		next, ok := b.queue.NextDue()
+		if expiry, expires := b.queue.NextExpiry(); expires && (!ok || expiry.Before(next)) {
+			next, ok = expiry, true
+		}
+		if ok {
+			due.Reset(time.Until(next))
+		} else {
+			due.Stop()
+		}
+
 		select {
 		case <-b.stopCh:
 			return
 		case <-b.injectCh:
 			b.processQueue()
+		case <-due.C:
+			b.processQueue()
 		}
 	}
 }
 
 func (b *Bridge) processQueue() {
-	if !b.busyDetector.IsIdle() {
+	b.expireQueue()
+	if !b.busyDetector.IsIdle() || b.IsPaused() || b.activePending() {
 		return
 	}
 
-	inj := b.queue.Dequeue()
-	if inj == nil {
+	batch := b.queue.DequeueBatch()
+	if len(batch) == 0 {
 		return
 	}
-
-	if b.verbose {
-		log.Printf("Injecting text (id=%s): %s", inj.ID, inj.Text)
+	if len(batch[0].Keys) > 0 {
+		b.injectKeys(batch[0])
+		return
 	}
+	text := b.batchText(batch)
 
+	for _, inj := range batch {
+		if b.verbose {
+			log.Printf("Injecting text (id=%s): %s", inj.ID, inj.Text)
+		}
+		b.history.Update(inj.ID, StateInjecting, nil)
+		b.events.Publish(EventInjectionStarted, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+	}
+	b.beginActive(batch...)
 	b.busyDetector.SetBusy()
-	err := b.pty.InjectText(inj.Text, !b.paranoid)
-	if err != nil && b.verbose {
-		log.Printf("Injection failed: %v", err)
+
+	echoed, err := b.pty.InjectText(text, !b.paranoid)
+	result := StateSubmitted
+	switch {
+	case err != nil:
+		if b.verbose {
+			log.Printf("Injection failed: %v", err)
+		}
+		for _, inj := range batch {
+			b.events.Publish(EventInjectionFailed, InjectionEvent{ID: inj.ID, Priority: inj.Priority, Error: err.Error()})
+		}
+		result = StateFailed
+		b.finishActive(err)
+	case b.paranoid:
+		b.markSubmitted()
+		for _, inj := range batch {
+			b.history.Update(inj.ID, StateSubmitted, nil)
+		}
+		b.finishActive(nil)
+	default:
+		b.markSubmitted()
+		for _, inj := range batch {
+			if echoed {
+				b.history.Update(inj.ID, StateEchoed, nil)
+			}
+			b.history.Update(inj.ID, StateSubmitted, nil)
+			b.events.Publish(EventInjectionSubmitted, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+		}
+		b.busyDetector.SetBusy()
+	}
+
+	for _, inj := range batch {
+		inj.Result = result
+		if inj.SyncChan != nil {
+			close(inj.SyncChan)
+		}
+	}
+}
+
+// injectKeys types a queued key sequence. Keys are not expected to start a
+// turn, so the injection completes as soon as they are sent.
+func (b *Bridge) injectKeys(inj *Injection) {
+	if b.verbose {
+		log.Printf("Injecting keys (id=%s): %v", inj.ID, inj.Keys)
 	}
+	b.history.Update(inj.ID, StateInjecting, nil)
+	b.events.Publish(EventInjectionStarted, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+	b.beginActive(inj)
 
+	inj.Result = StateSubmitted
+	if err := b.pty.SendKeys(inj.Keys); err != nil {
+		b.events.Publish(EventInjectionFailed, InjectionEvent{ID: inj.ID, Priority: inj.Priority, Error: err.Error()})
+		inj.Result = StateFailed
+		b.finishActive(err)
+	} else {
+		b.markSubmitted()
+		b.history.Update(inj.ID, StateSubmitted, nil)
+		b.events.Publish(EventInjectionSubmitted, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+		b.finishActive(nil)
+	}
 	if inj.SyncChan != nil {
 		close(inj.SyncChan)
 	}
 }
 
-func (b *Bridge) NotifyEnqueue() {
+// batchText joins the texts of injections typed in one submission.
+func (b *Bridge) batchText(batch []*Injection) string {
+	if len(batch) == 1 {
+		return batch[0].Text
+	}
+	texts := make([]string, len(batch))
+	for i, inj := range batch {
+		texts[i] = inj.Text
+	}
+	text := strings.Join(texts, b.batchSep)
+	if b.batchHeader != "" {
+		text = b.batchHeader + "\n" + text
+	}
+	return text
+}
+
+// Enqueue queues text. If opts.IdempotencyKey was already used within the
+// retention window, nothing is queued and a *DuplicateError carrying the
+// original injection's ID is returned.
+func (b *Bridge) Enqueue(text string, opts EnqueueOptions) (*Injection, error) {
+	var inj *Injection
+	if opts.IdempotencyKey == "" {
+		var err error
+		if inj, err = b.enqueue(text, opts); err != nil {
+			return nil, err
+		}
+	} else {
+		id, duplicate, err := b.idempotency.Do(opts.IdempotencyKey, func() (string, error) {
+			var err error
+			if inj, err = b.enqueue(text, opts); err != nil {
+				return "", err
+			}
+			return inj.ID, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if duplicate {
+			return nil, &DuplicateError{ID: id}
+		}
+	}
+
+	// Preempting writes to the PTY, which can block, so it happens outside
+	// the idempotency store's lock.
+	if opts.Preempt {
+		inj.Preempted = b.preempt(inj)
+	}
+	b.NotifyEnqueue()
+	return inj, nil
+}
+
+func (b *Bridge) enqueue(text string, opts EnqueueOptions) (*Injection, error) {
+	inj := b.newInjection(text, opts)
+	b.history.Add(inj)
+	replaced, err := b.queue.EnqueueInjection(inj)
+	if err != nil {
+		b.history.Remove(inj.ID)
+		return nil, err
+	}
+
+	b.events.Publish(EventInjectionQueued, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+	if replaced != nil {
+		if inj.Priority != opts.Priority {
+			b.history.Edit(inj.ID, inj.Text, inj.Priority)
+		}
+		inj.Replaced = replaced.ID
+		b.release(replaced, StateSuperseded)
+		b.events.Publish(EventInjectionSuperseded, InjectionSupersededEvent{ID: replaced.ID, ReplacedBy: inj.ID})
+	}
+	return inj, nil
+}
+
+// EnqueueSequence queues texts as one sequence: they are typed in order, each
+// once the tool has finished with the previous one, and nothing else is
+// injected in between. opts applies to every text; Key and Batch are
+// ignored. Either all texts are queued or none.
+func (b *Bridge) EnqueueSequence(texts []string, opts EnqueueOptions) ([]*Injection, error) {
+	opts.Key = ""
+	sequence := uuid.New().String()
+	injs := make([]*Injection, len(texts))
+	for i, text := range texts {
+		injs[i] = b.newInjection(text, opts)
+		injs[i].Sequence = sequence
+		injs[i].Batch = false
+		b.history.Add(injs[i])
+	}
+
+	if err := b.queue.EnqueueSequence(injs); err != nil {
+		for _, inj := range injs {
+			b.history.Remove(inj.ID)
+		}
+		return nil, err
+	}
+
+	for _, inj := range injs {
+		b.events.Publish(EventInjectionQueued, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+	}
+	if opts.Preempt {
+		injs[0].Preempted = b.preempt(injs...)
+	}
+	b.NotifyEnqueue()
+	return injs, nil
+}
+
+// EnqueueKeys queues named keys, such as "esc" or "ctrl-c", to be typed
+// when the tool is idle. Key and Batch in opts are ignored.
+func (b *Bridge) EnqueueKeys(keys []string, opts EnqueueOptions) (*Injection, error) {
+	if err := ValidateKeys(keys); err != nil {
+		return nil, err
+	}
+	opts.Key = ""
+	opts.Batch = false
+	inj := b.newInjection("", opts)
+	inj.Keys = keys
+	inj.Batch = false
+
+	b.history.Add(inj)
+	if _, err := b.queue.EnqueueInjection(inj); err != nil {
+		b.history.Remove(inj.ID)
+		return nil, err
+	}
+	b.events.Publish(EventInjectionQueued, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+	b.NotifyEnqueue()
+	return inj, nil
+}
+
+// Interrupt sends the interrupt key if the tool is busy and waits until it
+// goes idle or ctx is done. It reports whether a turn was interrupted; a
+// tool that is already idle is left alone.
+func (b *Bridge) Interrupt(ctx context.Context) (bool, error) {
 	if b.busyDetector.IsIdle() {
-		b.triggerInject()
+		return false, nil
+	}
+	if b.verbose {
+		log.Printf("Interrupting with %s", b.interruptKey)
+	}
+	if err := b.pty.SendKeys([]string{b.interruptKey}); err != nil {
+		return false, err
 	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for !b.busyDetector.IsIdle() {
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-ticker.C:
+		}
+	}
+	return true, nil
+}
+
+// preempt moves injs, a single injection or a whole sequence, to the front
+// of the queue and sends the interrupt key, so that they are injected as
+// soon as the tool goes idle. It does nothing if the tool is idle, the queue
+// is paused, injs are not due yet, a sequence is being typed or the last
+// preemption was less than Options.PreemptInterval ago, and reports whether
+// the key was sent.
+func (b *Bridge) preempt(injs ...*Injection) bool {
+	inj := injs[0]
+	if b.busyDetector.IsIdle() || b.IsPaused() || inj.NotBefore.After(time.Now()) {
+		return false
+	}
+
+	b.preemptMu.Lock()
+	defer b.preemptMu.Unlock()
+
+	if !b.lastPreempt.IsZero() && time.Since(b.lastPreempt) < b.preemptEvery {
+		if b.verbose {
+			log.Printf("Not preempting for %s: last preemption too recent", inj.ID)
+		}
+		return false
+	}
+	if err := b.queue.MoveToFront(inj.ID); err != nil {
+		if b.verbose {
+			log.Printf("Not preempting for %s: %v", inj.ID, err)
+		}
+		return false
+	}
+	for _, item := range injs {
+		b.history.Edit(item.ID, item.Text, MaxPriority)
+	}
+	b.events.Publish(EventInjectionUpdated, InjectionUpdatedEvent{ID: inj.ID, Position: 1})
+
+	if b.verbose {
+		log.Printf("Preempting for %s with %s", inj.ID, b.interruptKey)
+	}
+	if err := b.pty.SendKeys([]string{b.interruptKey}); err != nil {
+		if b.verbose {
+			log.Printf("Preempt failed: %v", err)
+		}
+		return false
+	}
+	b.lastPreempt = time.Now()
+	return true
+}
+
+// SendKeys types named keys right away, bypassing the queue.
+func (b *Bridge) SendKeys(keys []string) error {
+	return b.pty.SendKeys(keys)
+}
+
+func (b *Bridge) newInjection(text string, opts EnqueueOptions) *Injection {
+	inj := &Injection{
+		ID:        uuid.New().String(),
+		Text:      text,
+		Priority:  opts.Priority,
+		Source:    opts.Source,
+		Key:       opts.Key,
+		Batch:     opts.Batch || b.batch,
+		NotBefore: opts.NotBefore,
+	}
+	ttl := opts.TTL
+	if ttl == 0 {
+		ttl = b.defaultTTL
+	}
+	if ttl > 0 {
+		inj.ExpiresAt = time.Now().Add(ttl)
+	}
+	if opts.Sync {
+		inj.SyncChan = make(chan struct{})
+	}
+	if opts.WaitResponse {
+		inj.ResponseChan = make(chan Response, 1)
+	}
+	return inj
+}
+
+func (b *Bridge) enqueueScheduled(s Schedule) (string, error) {
+	source := s.Source
+	if source == "" {
+		source = "schedule"
+	}
+	inj, err := b.Enqueue(s.Text, EnqueueOptions{Priority: s.Priority, Source: source})
+	if err != nil {
+		if b.verbose {
+			log.Printf("Scheduled injection failed (schedule=%s): %v", s.ID, err)
+		}
+		return "", err
+	}
+	return inj.ID, nil
+}
+
+func (b *Bridge) ClearQueue() int {
+	items := b.queue.Drain()
+	for _, inj := range items {
+		b.release(inj, StateCancelled)
+	}
+	b.events.Publish(EventQueueCleared, QueueClearedEvent{Cleared: len(items)})
+	return len(items)
+}
+
+// expireQueue drops queued injections that outlived their TTL.
+func (b *Bridge) expireQueue() {
+	for _, inj := range b.queue.Expire(time.Now()) {
+		if b.verbose {
+			log.Printf("Injection expired (id=%s)", inj.ID)
+		}
+		b.release(inj, StateExpired)
+		b.events.Publish(EventInjectionExpired, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+	}
+}
+
+// CancelInjection removes a single pending injection from the queue.
+func (b *Bridge) CancelInjection(id string) bool {
+	inj := b.queue.Remove(id)
+	if inj == nil {
+		return false
+	}
+	b.release(inj, StateCancelled)
+	b.events.Publish(EventInjectionCancelled, InjectionEvent{ID: inj.ID, Priority: inj.Priority})
+	return true
+}
+
+// UpdateInjection edits a pending injection and returns its new zero-based
+// position.
+func (b *Bridge) UpdateInjection(id string, u QueueUpdate) (int, error) {
+	inj, pos, err := b.queue.Update(id, u)
+	if err != nil {
+		return 0, err
+	}
+	b.history.Edit(id, inj.Text, inj.Priority)
+	b.events.Publish(EventInjectionUpdated, InjectionUpdatedEvent{ID: id, Position: pos + 1})
+	return pos, nil
+}
+
+// release records that an injection left the queue without being typed and
+// unblocks callers waiting on it.
+func (b *Bridge) release(inj *Injection, state InjectionState) {
+	b.history.Update(inj.ID, state, nil)
+	inj.Result = state
+	if inj.SyncChan != nil {
+		close(inj.SyncChan)
+	}
+	if inj.ResponseChan != nil {
+		inj.ResponseChan <- Response{State: state}
+	}
+}
+
+// Pause stops injecting queued text until Resume is called. New injections
+// are still accepted. It reports whether the bridge was running before.
+func (b *Bridge) Pause() bool {
+	b.mu.Lock()
+	changed := !b.paused
+	b.paused = true
+	b.mu.Unlock()
+
+	if changed {
+		if b.verbose {
+			log.Printf("Queue paused")
+		}
+		b.events.Publish(EventQueuePaused, nil)
+	}
+	return changed
+}
+
+// Resume undoes Pause and injects the next item if the tool is idle. It
+// reports whether the bridge was paused before.
+func (b *Bridge) Resume() bool {
+	b.mu.Lock()
+	changed := b.paused
+	b.paused = false
+	b.mu.Unlock()
+
+	if changed {
+		if b.verbose {
+			log.Printf("Queue resumed")
+		}
+		b.events.Publish(EventQueueResumed, nil)
+		b.NotifyEnqueue()
+	}
+	return changed
+}
+
+func (b *Bridge) togglePause() {
+	if !b.Pause() {
+		b.Resume()
+	}
+}
+
+func (b *Bridge) IsPaused() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.paused
+}
+
+// NotifyEnqueue wakes the injection loop after the queue changed. It does so
+// even while the tool is busy, so the loop re-arms its timer for injections
+// that become due or expire in the meantime.
+func (b *Bridge) NotifyEnqueue() {
+	b.triggerInject()
 }
 
 func (b *Bridge) Wait() error {
@@ -114,10 +752,21 @@ func (b *Bridge) Wait() error {
 	b.running = false
 	b.mu.Unlock()
 	close(b.stopCh)
+	b.finishActive(errChildExited)
+
+	exited := ChildExitedEvent{ExitCode: b.pty.ExitCode()}
+	if err != nil {
+		exited.Error = err.Error()
+	}
+	b.events.Publish(EventChildExited, exited)
 	return err
 }
 
 func (b *Bridge) Close() error {
+	b.busyDetector.Close()
+	b.schedules.Close()
+	b.events.Close()
+	_ = b.journal.Close()
 	return b.pty.Close()
 }
 
@@ -125,6 +774,26 @@ func (b *Bridge) Queue() *Queue {
 	return b.queue
 }
 
+func (b *Bridge) Injection(id string) (InjectionRecord, bool) {
+	return b.history.Get(id)
+}
+
+func (b *Bridge) Schedules() *Scheduler {
+	return b.schedules
+}
+
+func (b *Bridge) Events() *EventBus {
+	return b.events
+}
+
+func (b *Bridge) Output() *OutputBuffer {
+	return b.output
+}
+
+func (b *Bridge) Screen() *vterm.Terminal {
+	return b.pty.Screen()
+}
+
 func (b *Bridge) IsIdle() bool {
 	return b.busyDetector.IsIdle()
 }
@@ -0,0 +1,248 @@
+package bridge
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestBridge returns a bridge whose child is never started. It is closed
+// when the test ends.
+func newTestBridge(t *testing.T, opts Options) *Bridge {
+	t.Helper()
+	if opts.ScrollbackSize == 0 {
+		opts.ScrollbackSize = 1024
+	}
+	b, err := New("true", nil, opts)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+// startTestBridge starts command headless and stops it when the test ends.
+func startTestBridge(t *testing.T, opts Options, command string, args ...string) *Bridge {
+	t.Helper()
+	opts.ScrollbackSize = 1 << 16
+	opts.Headless, opts.Cols, opts.Rows = true, 80, 24
+	opts.DiscardOutput = true
+	b, err := New(command, args, opts)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := b.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.pty.cmd.Process.Kill()
+		_ = b.Wait()
+		_ = b.Close()
+	})
+	return b
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClearQueueCancelsInjections(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	inj, _ := b.Enqueue("pending", EnqueueOptions{})
+	if n := b.ClearQueue(); n != 1 {
+		t.Errorf("ClearQueue() = %d, want 1", n)
+	}
+
+	rec, _ := b.Injection(inj.ID)
+	if rec.State != StateCancelled {
+		t.Errorf("State = %q, want %q", rec.State, StateCancelled)
+	}
+}
+
+func TestCancelInjectionReleasesWaiters(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	syncInj, _ := b.Enqueue("sync", EnqueueOptions{Sync: true})
+	respInj, _ := b.Enqueue("response", EnqueueOptions{WaitResponse: true})
+
+	if !b.CancelInjection(syncInj.ID) {
+		t.Fatal("CancelInjection() = false, want true")
+	}
+	if b.CancelInjection(syncInj.ID) {
+		t.Error("CancelInjection() of a cancelled item should return false")
+	}
+	<-syncInj.SyncChan
+	if syncInj.Result != StateCancelled {
+		t.Errorf("Result = %q, want %q", syncInj.Result, StateCancelled)
+	}
+
+	b.ClearQueue()
+	if resp := <-respInj.ResponseChan; resp.State != StateCancelled {
+		t.Errorf("Response.State = %q, want %q", resp.State, StateCancelled)
+	}
+}
+
+func TestExpireQueueReleasesWaiters(t *testing.T) {
+	b := newTestBridge(t, Options{DefaultTTL: time.Hour})
+
+	fresh, _ := b.Enqueue("fresh", EnqueueOptions{})
+	if time.Until(fresh.ExpiresAt) < 59*time.Minute {
+		t.Errorf("ExpiresAt = %v, want the default TTL applied", fresh.ExpiresAt)
+	}
+
+	stale, _ := b.Enqueue("stale", EnqueueOptions{Sync: true, TTL: time.Millisecond})
+	time.Sleep(5 * time.Millisecond)
+	b.expireQueue()
+
+	<-stale.SyncChan
+	if stale.Result != StateExpired {
+		t.Errorf("Result = %q, want %q", stale.Result, StateExpired)
+	}
+	if rec, _ := b.Injection(stale.ID); rec.State != StateExpired {
+		t.Errorf("State = %q, want %q", rec.State, StateExpired)
+	}
+	if b.Queue().Len() != 1 {
+		t.Errorf("Len() = %d, want 1", b.Queue().Len())
+	}
+}
+
+func TestEnqueueKeySupersedes(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	old, _ := b.Enqueue("screen 1", EnqueueOptions{Key: "screen", Priority: 4, Sync: true})
+	inj, err := b.Enqueue("screen 2", EnqueueOptions{Key: "screen"})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if inj.Replaced != old.ID {
+		t.Errorf("Replaced = %q, want %q", inj.Replaced, old.ID)
+	}
+
+	<-old.SyncChan
+	if old.Result != StateSuperseded {
+		t.Errorf("Result = %q, want %q", old.Result, StateSuperseded)
+	}
+	if rec, _ := b.Injection(old.ID); rec.State != StateSuperseded {
+		t.Errorf("State = %q, want %q", rec.State, StateSuperseded)
+	}
+	if rec, _ := b.Injection(inj.ID); rec.Priority != 4 {
+		t.Errorf("Priority = %d, want the superseded item's 4", rec.Priority)
+	}
+	if b.Queue().Len() != 1 {
+		t.Errorf("Len() = %d, want 1", b.Queue().Len())
+	}
+}
+
+func TestPauseHoldsQueue(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	if !b.Pause() || b.Pause() {
+		t.Error("Pause() should only report a change the first time")
+	}
+	if _, err := b.Enqueue("held", EnqueueOptions{}); err != nil {
+		t.Fatalf("Enqueue while paused failed: %v", err)
+	}
+	b.processQueue()
+	if b.Queue().Len() != 1 {
+		t.Errorf("Len() = %d, want the injection held while paused", b.Queue().Len())
+	}
+
+	b.togglePause()
+	if b.IsPaused() {
+		t.Error("togglePause() should resume a paused bridge")
+	}
+	b.togglePause()
+	if !b.IsPaused() {
+		t.Error("togglePause() should pause a running bridge")
+	}
+}
+
+func TestExpireWhileBusy(t *testing.T) {
+	b := startTestBridge(t, Options{BusyPattern: "working"}, "sh", "-c", "echo working; sleep 5")
+	waitFor(t, "the tool to go busy", func() bool { return !b.IsIdle() })
+
+	inj, err := b.Enqueue("stale", EnqueueOptions{TTL: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	waitFor(t, "the injection to expire", func() bool {
+		rec, _ := b.Injection(inj.ID)
+		return rec.State == StateExpired
+	})
+	if b.IsIdle() {
+		t.Error("the tool should still be busy")
+	}
+}
+
+func TestInterruptIdle(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	interrupted, err := b.Interrupt(context.Background())
+	if err != nil || interrupted {
+		t.Errorf("Interrupt() = %v, %v, want false for an idle tool", interrupted, err)
+	}
+
+	if _, err := New("true", nil, Options{InterruptKey: "ctrl-"}); err == nil {
+		t.Error("New should reject an invalid interrupt key")
+	}
+}
+
+func TestPreempt(t *testing.T) {
+	// The child shows a busy line until it reads a byte, then prints it.
+	b := startTestBridge(t, Options{BusyPattern: "working", InterruptKey: "esc", PreemptInterval: time.Minute},
+		"sh", "-c", "stty raw -echo; echo working; dd bs=1 count=1 2>/dev/null | od -An -tx1; sleep 5")
+	waitFor(t, "the tool to go busy", func() bool { return !b.IsIdle() })
+
+	queued, _ := b.Enqueue("queued", EnqueueOptions{})
+	later, _ := b.Enqueue("later", EnqueueOptions{Preempt: true, NotBefore: time.Now().Add(time.Hour)})
+	if later.Preempted {
+		t.Error("an injection that is not due should not preempt")
+	}
+
+	urgent, err := b.Enqueue("urgent", EnqueueOptions{Preempt: true, IdempotencyKey: "urgent"})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if !urgent.Preempted {
+		t.Fatal("Preempted = false, want the busy tool interrupted")
+	}
+	if pos, _ := b.Queue().Position(urgent.ID); pos != 0 {
+		t.Errorf("Position() = %d, want the preempting injection first", pos)
+	}
+	if pos, _ := b.Queue().Position(queued.ID); pos != 1 {
+		t.Errorf("Position() = %d, want the earlier injection second", pos)
+	}
+	if rec, _ := b.Injection(urgent.ID); rec.Priority != MaxPriority {
+		t.Errorf("Priority = %d, want %d", rec.Priority, MaxPriority)
+	}
+	waitFor(t, "the interrupt key to reach the child", func() bool {
+		return strings.Contains(strings.Join(b.Screen().Lines(), "\n"), "1b")
+	})
+
+	again, _ := b.Enqueue("again", EnqueueOptions{Preempt: true})
+	if again.Preempted {
+		t.Error("a second preemption within the interval should be skipped")
+	}
+}
+
+func TestPreemptIdle(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	inj, _ := b.Enqueue("idle", EnqueueOptions{Preempt: true})
+	if inj.Preempted {
+		t.Error("an idle tool should not be preempted")
+	}
+	if rec, _ := b.Injection(inj.ID); rec.Priority != 0 {
+		t.Errorf("Priority = %d, want it unchanged without preemption", rec.Priority)
+	}
+}
@@ -2,25 +2,66 @@ package bridge
 
 import (
 	"log"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	readySettle  = 150 * time.Millisecond
+	readyTimeout = 5 * time.Second
+	// staleTimeout ends a busy period when the busy pattern is still
+	// visible but the screen has stopped changing. Working tools animate a
+	// spinner or a timer; a busy word left in the transcript does not.
+	staleTimeout = 10 * time.Second
+)
+
 type BusyDetector struct {
-	mu          sync.RWMutex
-	idle        bool
-	lastOutput  time.Time
-	onIdle      func()
-	verbose     bool
-	idleTimeout time.Duration
+	mu           sync.RWMutex
+	idle         bool
+	lastBusy     time.Time
+	lastChange   time.Time
+	readyHold    time.Time
+	lastScreen   string
+	busyVisible  bool
+	busySignal   bool
+	readyVisible bool
+	pattern      *regexp.Regexp
+	ready        *regexp.Regexp
+	onChange     func(idle bool)
+	verbose      bool
+	idleTimeout  time.Duration
+	readySettle  time.Duration
+	readyTimeout time.Duration
+	staleTimeout time.Duration
+	stop         chan struct{}
+	stopOnce     sync.Once
 }
 
-func NewBusyDetector(pattern string, onIdle func(), verbose bool) (*BusyDetector, error) {
+// NewBusyDetector creates a detector that calls onChange whenever the tool
+// switches between busy and idle.
+func NewBusyDetector(pattern, readyPattern string, onChange func(idle bool), verbose bool) (*BusyDetector, error) {
+	re, err := compileOptional(pattern)
+	if err != nil {
+		return nil, err
+	}
+	ready, err := compileOptional(readyPattern)
+	if err != nil {
+		return nil, err
+	}
+
 	d := &BusyDetector{
-		idle:        true,
-		onIdle:      onIdle,
-		verbose:     verbose,
-		idleTimeout: 500 * time.Millisecond,
+		idle:         true,
+		pattern:      re,
+		ready:        ready,
+		onChange:     onChange,
+		verbose:      verbose,
+		idleTimeout:  500 * time.Millisecond,
+		readySettle:  readySettle,
+		readyTimeout: readyTimeout,
+		staleTimeout: staleTimeout,
+		stop:         make(chan struct{}),
 	}
 
 	go d.checkIdleLoop()
@@ -32,32 +73,140 @@ func (d *BusyDetector) checkIdleLoop() {
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+
 		d.mu.Lock()
 		wasIdle := d.idle
-		if !d.idle && time.Since(d.lastOutput) > d.idleTimeout {
-			d.idle = true
-			if d.verbose {
-				log.Printf("Idle timeout - no output for %v", d.idleTimeout)
-			}
+		if !d.idle {
+			d.checkIdle()
 		}
 		isIdle := d.idle
 		d.mu.Unlock()
 
-		if isIdle && !wasIdle && d.onIdle != nil {
-			d.onIdle()
+		if isIdle && !wasIdle {
+			d.notify(true)
 		}
 	}
 }
 
-func (d *BusyDetector) ProcessLine(line string) {
-	if d.verbose {
-		log.Printf("PTY line: %q", line)
+// Close stops the detector's idle checks.
+func (d *BusyDetector) Close() {
+	d.stopOnce.Do(func() { close(d.stop) })
+}
+
+func compileOptional(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// checkIdle must be called with d.mu held. The tool stays busy while the
+// busy pattern is on screen, unless the screen has not changed for
+// staleTimeout. A visible ready prompt ends the busy period once the
+// screen settles; otherwise the detector waits out the timeout, which is
+// longer when silence alone is the only busy signal but a ready pattern is
+// expected to show up.
+func (d *BusyDetector) checkIdle() {
+	now := time.Now()
+	if d.busyVisible && now.Sub(d.lastChange) <= d.staleTimeout {
+		d.lastBusy = now
+		return
+	}
+
+	quiet := now.Sub(d.lastBusy)
+
+	settled := quiet > d.readySettle && now.Sub(d.lastChange) > d.readySettle
+	if d.readyVisible && now.After(d.readyHold) && settled {
+		d.idle = true
+		if d.verbose {
+			log.Printf("Ready pattern matched - idle")
+		}
+		return
+	}
+
+	timeout := d.idleTimeout
+	if d.pattern == nil && d.ready != nil {
+		timeout = d.readyTimeout
+	}
+	if quiet > timeout {
+		d.idle = true
+		if d.verbose {
+			if d.pattern != nil {
+				log.Printf("Idle timeout - busy pattern not seen for %v", timeout)
+			} else {
+				log.Printf("Idle timeout - no output for %v", timeout)
+			}
+		}
 	}
+}
+
+// ProcessScreen evaluates the rendered screen after new output and reports
+// whether it differs from the previous one. The busy and ready patterns are
+// matched line by line. Without a busy pattern any change to the screen
+// counts as activity.
+func (d *BusyDetector) ProcessScreen(lines []string) bool {
+	busy := d.pattern != nil && matchAny(d.pattern, lines)
+	ready := d.ready != nil && matchAny(d.ready, lines)
+	text := strings.Join(lines, "\n")
+	now := time.Now()
+
 	d.mu.Lock()
-	d.idle = false
-	d.lastOutput = time.Now()
+	changed := text != d.lastScreen
+	d.lastScreen = text
+	if changed {
+		d.lastChange = now
+	}
+	d.busyVisible = busy
+	if busy && now.Sub(d.lastChange) > d.staleTimeout {
+		busy = false
+	}
+	d.readyVisible = ready
+
+	d.busySignal = busy || (d.pattern == nil && changed)
+	becameBusy := false
+	if d.busySignal {
+		if d.verbose && busy && d.idle {
+			log.Printf("Busy pattern visible")
+		}
+		becameBusy = d.idle
+		d.idle = false
+		d.lastBusy = now
+	}
 	d.mu.Unlock()
+
+	if becameBusy {
+		d.notify(false)
+	}
+	return changed
+}
+
+func (d *BusyDetector) notify(idle bool) {
+	if d.onChange != nil {
+		d.onChange(idle)
+	}
+}
+
+func matchAny(re *regexp.Regexp, lines []string) bool {
+	for _, line := range lines {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// BusySignal reports whether the last screen showed the tool working: the
+// busy pattern was visible or, without a pattern, the screen changed.
+func (d *BusyDetector) BusySignal() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.busySignal
 }
 
 func (d *BusyDetector) IsIdle() bool {
@@ -66,9 +215,17 @@ func (d *BusyDetector) IsIdle() bool {
 	return d.idle
 }
 
+// SetBusy marks the tool busy after an injection. A ready prompt is ignored
+// for one idle timeout so the tool has a chance to start working.
 func (d *BusyDetector) SetBusy() {
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	wasIdle := d.idle
 	d.idle = false
-	d.lastOutput = time.Now()
+	d.lastBusy = time.Now()
+	d.readyHold = d.lastBusy.Add(d.idleTimeout)
+	d.mu.Unlock()
+
+	if wasIdle {
+		d.notify(false)
+	}
 }
@@ -1,25 +1,31 @@
 package bridge
 
 import (
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/MobAI-App/aibridge/internal/patterns"
 )
 
 func TestBusyDetectorOutput(t *testing.T) {
 	var called int32
-	d, err := NewBusyDetector("", func() {
-		atomic.AddInt32(&called, 1)
+	d, err := NewBusyDetector("", "", func(idle bool) {
+		if idle {
+			atomic.AddInt32(&called, 1)
+		}
 	}, false)
 	if err != nil {
 		t.Fatalf("NewBusyDetector failed: %v", err)
 	}
+	defer d.Close()
 
 	if !d.IsIdle() {
 		t.Error("Should be idle initially")
 	}
 
-	d.ProcessLine("any output at all")
+	d.ProcessScreen([]string{"any output at all"})
 	if d.IsIdle() {
 		t.Error("Should be busy after any output")
 	}
@@ -34,7 +40,8 @@ func TestBusyDetectorOutput(t *testing.T) {
 }
 
 func TestBusyDetectorSetBusy(t *testing.T) {
-	d, _ := NewBusyDetector("", nil, false)
+	d, _ := NewBusyDetector("", "", nil, false)
+	defer d.Close()
 
 	if !d.IsIdle() {
 		t.Error("Should be idle initially")
@@ -45,3 +52,158 @@ func TestBusyDetectorSetBusy(t *testing.T) {
 		t.Error("Should not be idle after SetBusy")
 	}
 }
+
+func TestBusyDetectorPattern(t *testing.T) {
+	d, err := NewBusyDetector(`esc to interrupt`, "", nil, false)
+	if err != nil {
+		t.Fatalf("NewBusyDetector failed: %v", err)
+	}
+	defer d.Close()
+
+	d.ProcessScreen([]string{"", "12:01:02 status bar redraw"})
+	if !d.IsIdle() {
+		t.Error("Should stay idle on output that doesn't match the pattern")
+	}
+
+	d.ProcessScreen([]string{"✻ Working… (esc to interrupt)", "> "})
+	if d.IsIdle() {
+		t.Error("Should be busy while the pattern is visible")
+	}
+
+	time.Sleep(700 * time.Millisecond)
+	if d.IsIdle() {
+		t.Error("Should stay busy while the pattern stays on screen")
+	}
+
+	d.ProcessScreen([]string{"Done.", "> "})
+	time.Sleep(300 * time.Millisecond)
+	if d.IsIdle() {
+		t.Error("Should not go idle before the timeout")
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	if !d.IsIdle() {
+		t.Error("Should be idle once the pattern is gone from the screen")
+	}
+}
+
+func TestBusyDetectorStalePattern(t *testing.T) {
+	d, err := NewBusyDetector(`thinking`, "", nil, false)
+	if err != nil {
+		t.Fatalf("NewBusyDetector failed: %v", err)
+	}
+	defer d.Close()
+	d.mu.Lock()
+	d.idleTimeout = 50 * time.Millisecond
+	d.staleTimeout = 100 * time.Millisecond
+	d.mu.Unlock()
+
+	screen := []string{"> I was thinking we could rename it", "● Sure, done.", "> "}
+	d.ProcessScreen(screen)
+	if d.IsIdle() {
+		t.Fatal("Should be busy when the pattern shows up")
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	if !d.IsIdle() {
+		t.Fatal("Should be idle once the screen stops changing, even with the pattern visible")
+	}
+
+	d.ProcessScreen(screen)
+	if !d.IsIdle() {
+		t.Error("A redraw of the same screen should not count as busy")
+	}
+
+	d.ProcessScreen(append(screen, "✻ thinking… 3s"))
+	if d.IsIdle() {
+		t.Error("Should be busy again when the screen changes")
+	}
+}
+
+func TestBusyDetectorInvalidPattern(t *testing.T) {
+	if _, err := NewBusyDetector(`(unclosed`, "", nil, false); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+	if _, err := NewBusyDetector("", `(unclosed`, nil, false); err == nil {
+		t.Error("Expected error for invalid ready pattern")
+	}
+}
+
+func TestBusyDetectorReadyPattern(t *testing.T) {
+	d, err := NewBusyDetector(`esc to interrupt`, `^> $`, nil, false)
+	if err != nil {
+		t.Fatalf("NewBusyDetector failed: %v", err)
+	}
+	defer d.Close()
+
+	d.ProcessScreen([]string{"(esc to interrupt)", "> "})
+	time.Sleep(300 * time.Millisecond)
+	if d.IsIdle() {
+		t.Error("Should stay busy while the busy pattern is visible next to the prompt")
+	}
+
+	d.ProcessScreen([]string{"Done.", "> "})
+	time.Sleep(300 * time.Millisecond)
+	if !d.IsIdle() {
+		t.Error("Should be idle once the prompt is drawn, before the idle timeout")
+	}
+}
+
+func TestBusyDetectorReadyPatternSilence(t *testing.T) {
+	d, err := NewBusyDetector("", `^> $`, nil, false)
+	if err != nil {
+		t.Fatalf("NewBusyDetector failed: %v", err)
+	}
+	defer d.Close()
+
+	d.ProcessScreen([]string{"streaming response..."})
+	time.Sleep(700 * time.Millisecond)
+	if d.IsIdle() {
+		t.Error("Should not go idle on a short pause when a ready pattern is expected")
+	}
+
+	d.ProcessScreen([]string{"streaming response... done", "> "})
+	time.Sleep(300 * time.Millisecond)
+	if !d.IsIdle() {
+		t.Error("Should be idle once the prompt is drawn")
+	}
+}
+
+func TestBusyDetectorReadyHoldAfterSetBusy(t *testing.T) {
+	d, err := NewBusyDetector(`esc to interrupt`, `^> $`, nil, false)
+	if err != nil {
+		t.Fatalf("NewBusyDetector failed: %v", err)
+	}
+	defer d.Close()
+
+	d.SetBusy()
+	d.ProcessScreen([]string{"> "})
+	time.Sleep(300 * time.Millisecond)
+	if d.IsIdle() {
+		t.Error("Prompt redraw right after injection should not end the busy period")
+	}
+}
+
+func TestBusyDetectorClaudeTurn(t *testing.T) {
+	p := patterns.GetPattern("claude")
+	d, err := NewBusyDetector(p.Regex, p.Ready, nil, false)
+	if err != nil {
+		t.Fatalf("NewBusyDetector failed: %v", err)
+	}
+	defer d.Close()
+
+	border := "╭" + strings.Repeat("─", 40) + "╮"
+	bottom := "╰" + strings.Repeat("─", 40) + "╯"
+	d.ProcessScreen([]string{"✻ thinking…", border, bottom})
+	if d.IsIdle() {
+		t.Fatal("Should be busy while thinking")
+	}
+
+	// The turn goes on without the busy pattern, with the input box drawn
+	// below the output.
+	d.ProcessScreen([]string{"● Read(main.go)", "  ⎿  Read 120 lines", border, bottom})
+	time.Sleep(300 * time.Millisecond)
+	if d.IsIdle() {
+		t.Error("the input box border alone should not end the turn")
+	}
+}
@@ -0,0 +1,220 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	eventHistorySize  = 1000
+	subscriberBacklog = 256
+)
+
+type EventType string
+
+const (
+	EventState               EventType = "state"
+	EventInjectionQueued     EventType = "injection_queued"
+	EventInjectionStarted    EventType = "injection_started"
+	EventInjectionSubmitted  EventType = "injection_submitted"
+	EventInjectionFailed     EventType = "injection_failed"
+	EventInjectionCancelled  EventType = "injection_cancelled"
+	EventInjectionExpired    EventType = "injection_expired"
+	EventInjectionSuperseded EventType = "injection_superseded"
+	EventInjectionUpdated    EventType = "injection_updated"
+	EventQueueCleared        EventType = "queue_cleared"
+	EventQueuePaused         EventType = "queue_paused"
+	EventQueueResumed        EventType = "queue_resumed"
+	EventChildExited         EventType = "child_exited"
+	EventOutput              EventType = "output"
+	EventEventsLost          EventType = "events_lost"
+)
+
+type Event struct {
+	ID   uint64    `json:"id"`
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+type StateEvent struct {
+	Idle bool `json:"idle"`
+}
+
+type InjectionEvent struct {
+	ID       string `json:"id"`
+	Priority int    `json:"priority"`
+	Error    string `json:"error,omitempty"`
+}
+
+// InjectionUpdatedEvent carries the injection's place in the queue,
+// counting from 1 like the rest of the HTTP API.
+type InjectionUpdatedEvent struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+}
+
+type InjectionSupersededEvent struct {
+	ID         string `json:"id"`
+	ReplacedBy string `json:"replaced_by"`
+}
+
+type QueueClearedEvent struct {
+	Cleared int `json:"cleared"`
+}
+
+type ChildExitedEvent struct {
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// EventsLostEvent tells a resuming subscriber that events after After up to
+// and including Until are no longer in the history.
+type EventsLostEvent struct {
+	After uint64 `json:"after"`
+	Until uint64 `json:"until"`
+}
+
+type OutputEvent struct {
+	Offset int64  `json:"offset"`
+	Data   string `json:"data"`
+}
+
+// EventBus fans out bridge events to subscribers and keeps a bounded
+// history so reconnecting clients can resume after the last ID they saw.
+// Output events are only delivered live: they would quickly push everything
+// else out of the history, and the output buffer already keeps them.
+// Subscribers that fall behind are dropped rather than blocking publishers.
+type EventBus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	evicted uint64
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+type Subscription struct {
+	C     <-chan Event
+	ch    chan Event
+	bus   *EventBus
+	types map[EventType]bool
+}
+
+// wants reports whether the subscriber asked for events of type typ.
+// EventEventsLost is always delivered.
+func (s *Subscription) wants(typ EventType) bool {
+	return s.types == nil || s.types[typ] || typ == EventEventsLost
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		nextID: 1,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+func (e *EventBus) Publish(typ EventType, data any) Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ev := Event{
+		ID:   e.nextID,
+		Type: typ,
+		Time: time.Now(),
+		Data: data,
+	}
+	e.nextID++
+
+	if e.closed {
+		return ev
+	}
+
+	if typ != EventOutput {
+		e.history = append(e.history, ev)
+		if n := len(e.history) - eventHistorySize; n > 0 {
+			e.evicted = e.history[n-1].ID
+			e.history = e.history[n:]
+		}
+	}
+
+	for sub := range e.subs {
+		if !sub.wants(typ) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			delete(e.subs, sub)
+			close(sub.ch)
+		}
+	}
+
+	return ev
+}
+
+// Subscribe returns the retained events newer than lastID together with a
+// subscription for events published afterwards. If events after a non-zero
+// lastID have already left the history, the replay starts with an
+// EventEventsLost event. If types are given, only events of those types are
+// replayed and queued for the subscriber, so a client that ignores output is
+// not dropped when output floods the bus.
+func (e *EventBus) Subscribe(lastID uint64, types ...EventType) ([]Event, *Subscription) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ch := make(chan Event, subscriberBacklog)
+	sub := &Subscription{C: ch, ch: ch, bus: e}
+	if len(types) > 0 {
+		sub.types = make(map[EventType]bool, len(types))
+		for _, typ := range types {
+			sub.types[typ] = true
+		}
+	}
+
+	var replay []Event
+	if lastID > 0 && lastID < e.evicted {
+		replay = append(replay, Event{
+			ID:   e.evicted,
+			Type: EventEventsLost,
+			Time: time.Now(),
+			Data: EventsLostEvent{After: lastID, Until: e.evicted},
+		})
+	}
+	for _, ev := range e.history {
+		if ev.ID > lastID && sub.wants(ev.Type) {
+			replay = append(replay, ev)
+		}
+	}
+
+	if e.closed {
+		close(ch)
+		return replay, sub
+	}
+	e.subs[sub] = struct{}{}
+	return replay, sub
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+func (e *EventBus) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return
+	}
+	e.closed = true
+	for sub := range e.subs {
+		delete(e.subs, sub)
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,152 @@
+package bridge
+
+import "testing"
+
+func TestEventBusPublishSubscribe(t *testing.T) {
+	bus := NewEventBus()
+
+	_, sub := bus.Subscribe(0)
+	defer sub.Close()
+
+	ev := bus.Publish(EventState, StateEvent{Idle: true})
+	if ev.ID != 1 {
+		t.Errorf("ID = %d, want 1", ev.ID)
+	}
+
+	got := <-sub.C
+	if got.ID != ev.ID || got.Type != EventState {
+		t.Errorf("received %+v, want %+v", got, ev)
+	}
+}
+
+func TestEventBusReplay(t *testing.T) {
+	bus := NewEventBus()
+	bus.Publish(EventInjectionQueued, InjectionEvent{ID: "a"})
+	bus.Publish(EventInjectionStarted, InjectionEvent{ID: "a"})
+	bus.Publish(EventInjectionSubmitted, InjectionEvent{ID: "a"})
+
+	replay, sub := bus.Subscribe(1)
+	defer sub.Close()
+
+	if len(replay) != 2 || replay[0].ID != 2 || replay[1].ID != 3 {
+		t.Errorf("replay = %+v, want events 2 and 3", replay)
+	}
+}
+
+func TestEventBusHistoryBounded(t *testing.T) {
+	bus := NewEventBus()
+	for i := 0; i < eventHistorySize+10; i++ {
+		bus.Publish(EventState, StateEvent{})
+	}
+
+	replay, sub := bus.Subscribe(0)
+	defer sub.Close()
+
+	if len(replay) != eventHistorySize {
+		t.Errorf("len(replay) = %d, want %d", len(replay), eventHistorySize)
+	}
+	if replay[0].ID != 11 {
+		t.Errorf("oldest retained ID = %d, want 11", replay[0].ID)
+	}
+}
+
+func TestEventBusOutputNotRetained(t *testing.T) {
+	bus := NewEventBus()
+	bus.Publish(EventState, StateEvent{Idle: false})
+	for i := 0; i < eventHistorySize+10; i++ {
+		bus.Publish(EventOutput, OutputEvent{})
+	}
+	bus.Publish(EventState, StateEvent{Idle: true})
+
+	replay, sub := bus.Subscribe(0)
+	defer sub.Close()
+	if len(replay) != 2 || replay[0].ID != 1 || replay[1].Type != EventState {
+		t.Errorf("replay = %+v, want both state events and no output", replay)
+	}
+}
+
+func TestEventBusEventsLost(t *testing.T) {
+	bus := NewEventBus()
+	for i := 0; i < eventHistorySize+10; i++ {
+		bus.Publish(EventState, StateEvent{})
+	}
+
+	replay, sub := bus.Subscribe(5)
+	sub.Close()
+	if len(replay) != eventHistorySize+1 || replay[0].Type != EventEventsLost {
+		t.Fatalf("replay starts with %+v, want an events_lost marker", replay[0])
+	}
+	if lost := replay[0].Data.(EventsLostEvent); lost.After != 5 || lost.Until != 10 {
+		t.Errorf("lost = %+v, want events 6 to 10", lost)
+	}
+
+	replay, sub = bus.Subscribe(10)
+	sub.Close()
+	if replay[0].Type == EventEventsLost {
+		t.Error("no events were lost after ID 10")
+	}
+}
+
+func TestEventBusDropsSlowSubscriber(t *testing.T) {
+	bus := NewEventBus()
+	_, sub := bus.Subscribe(0)
+
+	for i := 0; i < subscriberBacklog+1; i++ {
+		bus.Publish(EventOutput, OutputEvent{})
+	}
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != subscriberBacklog {
+		t.Errorf("received %d events before drop, want %d", n, subscriberBacklog)
+	}
+
+	sub.Close()
+}
+
+func TestEventBusClose(t *testing.T) {
+	bus := NewEventBus()
+	_, sub := bus.Subscribe(0)
+
+	bus.Close()
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription channel should be closed")
+	}
+
+	_, late := bus.Subscribe(0)
+	if _, ok := <-late.C; ok {
+		t.Error("subscribing to a closed bus should return a closed channel")
+	}
+}
+
+func TestEventBusTypeFilter(t *testing.T) {
+	bus := NewEventBus()
+	bus.Publish(EventInjectionQueued, InjectionEvent{ID: "a"})
+	bus.Publish(EventState, StateEvent{Idle: false})
+
+	replay, sub := bus.Subscribe(0, EventState)
+	defer sub.Close()
+	if len(replay) != 1 || replay[0].Type != EventState {
+		t.Fatalf("replay = %+v, want only the state event", replay)
+	}
+
+	// An output flood must not fill a state-only subscriber's backlog.
+	for i := 0; i < subscriberBacklog*4; i++ {
+		bus.Publish(EventOutput, OutputEvent{})
+	}
+	bus.Publish(EventState, StateEvent{Idle: true})
+
+	select {
+	case ev, ok := <-sub.C:
+		if !ok {
+			t.Fatal("state-only subscriber was dropped by an output flood")
+		}
+		if ev.Type != EventState {
+			t.Errorf("received %s, want state", ev.Type)
+		}
+	default:
+		t.Fatal("state event was not delivered")
+	}
+}
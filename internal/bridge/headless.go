@@ -0,0 +1,29 @@
+package bridge
+
+import "io"
+
+// SetHeadless makes Start run the child without the local terminal: stdin is
+// neither switched to raw mode nor forwarded, and the child gets a cols x
+// rows terminal instead of the local one's size. The HTTP API and the
+// in-memory screen are then the only way to interact with it. It must be
+// called before Start.
+func (p *PTY) SetHeadless(cols, rows int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.headless = true
+	p.cols, p.rows = cols, rows
+	p.screen.Resize(cols, rows)
+}
+
+// SetOutput sets where the child's output is mirrored, os.Stdout by default.
+// Nil discards it. It must be called before Start.
+func (p *PTY) SetOutput(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if w == nil {
+		w = io.Discard
+	}
+	p.stdout = w
+}
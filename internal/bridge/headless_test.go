@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeadlessStart(t *testing.T) {
+	p := NewPTY("sh", []string{"-c", "stty size; sleep 5"}, 0)
+	p.SetHeadless(100, 30)
+	p.SetOutput(nil)
+
+	var mu sync.Mutex
+	var output strings.Builder
+	if err := p.Start(func(data []byte) {
+		mu.Lock()
+		output.Write(data)
+		mu.Unlock()
+	}); err != nil {
+		t.Fatalf("Start failed without a terminal: %v", err)
+	}
+	defer func() {
+		_ = p.Close()
+		_ = p.cmd.Process.Kill()
+		_ = p.Wait()
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		got := output.String()
+		mu.Unlock()
+		if strings.Contains(got, "30 100") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("output = %q, want the configured size 30 100", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if cols, rows := p.Screen().Size(); cols != 100 || rows != 30 {
+		t.Errorf("screen size = %dx%d, want 100x30", cols, rows)
+	}
+}
+
+func TestHeadlessOptions(t *testing.T) {
+	if _, err := New("true", nil, Options{Headless: true}); err == nil {
+		t.Error("New should reject headless mode without a terminal size")
+	}
+	newTestBridge(t, Options{Headless: true, Cols: 120, Rows: 40})
+}
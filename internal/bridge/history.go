@@ -0,0 +1,194 @@
+package bridge
+
+import (
+	"sync"
+	"time"
+)
+
+const DefaultHistorySize = 1000
+
+type InjectionState string
+
+const (
+	StateQueued          InjectionState = "queued"
+	StateInjecting       InjectionState = "injecting"
+	StateEchoed          InjectionState = "echoed"
+	StateSubmitted       InjectionState = "submitted"
+	StateResponseStarted InjectionState = "response_started"
+	StateCompleted       InjectionState = "completed"
+	StateFailed          InjectionState = "failed"
+	StateCancelled       InjectionState = "cancelled"
+	StateExpired         InjectionState = "expired"
+	StateSuperseded      InjectionState = "superseded"
+)
+
+// Final reports whether no further transitions are expected.
+func (s InjectionState) Final() bool {
+	switch s {
+	case StateCompleted, StateFailed, StateCancelled, StateExpired, StateSuperseded:
+		return true
+	}
+	return false
+}
+
+type StateChange struct {
+	State InjectionState
+	Time  time.Time
+	Error string
+}
+
+type InjectionRecord struct {
+	ID          string
+	Priority    int
+	State       InjectionState
+	Error       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Transitions []StateChange
+}
+
+// History records the lifecycle of recent injections. It keeps the last
+// size records in insertion order and forgets older ones.
+type History struct {
+	mu      sync.Mutex
+	size    int
+	records map[string]*InjectionRecord
+	order   []string
+	journal *Journal
+}
+
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &History{
+		size:    size,
+		records: make(map[string]*InjectionRecord),
+	}
+}
+
+// SetJournal makes the history persist every change it records to j.
+func (h *History) SetJournal(j *Journal) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.journal = j
+}
+
+// Add records inj as queued. Its enqueue time is set if unset so the
+// record and the queue agree on it.
+func (h *History) Add(inj *Injection) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if inj.EnqueuedAt.IsZero() {
+		inj.EnqueuedAt = time.Now()
+	}
+	h.insert(&InjectionRecord{
+		ID:          inj.ID,
+		Priority:    inj.Priority,
+		State:       StateQueued,
+		CreatedAt:   inj.EnqueuedAt,
+		UpdatedAt:   inj.EnqueuedAt,
+		Transitions: []StateChange{{State: StateQueued, Time: inj.EnqueuedAt}},
+	})
+	h.journal.enqueue(inj)
+}
+
+// restore inserts a record replayed from the journal without journaling it
+// again.
+func (h *History) restore(rec *InjectionRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.insert(rec)
+}
+
+func (h *History) insert(rec *InjectionRecord) {
+	h.records[rec.ID] = rec
+	h.order = append(h.order, rec.ID)
+
+	for len(h.order) > h.size {
+		delete(h.records, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+// Remove forgets a record. Like Update and Edit, it is journaled even if the
+// record was already evicted, since the injection may still be queued.
+func (h *History) Remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.journal.remove(id)
+	if _, ok := h.records[id]; !ok {
+		return
+	}
+	delete(h.records, id)
+	for i, v := range h.order {
+		if v == id {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Update moves a record to state. Records that already reached a final
+// state are left untouched.
+func (h *History) Update(id string, state InjectionState, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	change := StateChange{State: state, Time: time.Now()}
+	if err != nil {
+		change.Error = err.Error()
+	}
+	rec, ok := h.records[id]
+	if !ok {
+		// Replaying the journal ignores changes after a final state, so
+		// an evicted record can be journaled without knowing its state.
+		h.journal.state(id, change)
+		return
+	}
+	if rec.apply(change) {
+		h.journal.state(id, change)
+	}
+}
+
+// Edit records a change to a queued injection's text or priority.
+func (h *History) Edit(id, text string, priority int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if rec, ok := h.records[id]; ok {
+		rec.Priority = priority
+	}
+	h.journal.edit(id, text, priority)
+}
+
+func (h *History) Get(id string) (InjectionRecord, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rec, ok := h.records[id]
+	if !ok {
+		return InjectionRecord{}, false
+	}
+	cp := *rec
+	cp.Transitions = append([]StateChange(nil), rec.Transitions...)
+	return cp, true
+}
+
+// apply appends change unless the record already reached a final state.
+func (rec *InjectionRecord) apply(change StateChange) bool {
+	if rec.State.Final() {
+		return false
+	}
+	if change.Error != "" {
+		rec.Error = change.Error
+	}
+	rec.State = change.State
+	rec.UpdatedAt = change.Time
+	rec.Transitions = append(rec.Transitions, change)
+	return true
+}
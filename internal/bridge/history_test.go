@@ -0,0 +1,56 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHistoryLifecycle(t *testing.T) {
+	h := NewHistory(10)
+	h.Add(&Injection{ID: "a", Priority: 3})
+
+	h.Update("a", StateInjecting, nil)
+	h.Update("a", StateFailed, errors.New("boom"))
+	h.Update("a", StateCompleted, nil)
+
+	rec, ok := h.Get("a")
+	if !ok {
+		t.Fatal("Get() should find the record")
+	}
+	if rec.State != StateFailed || rec.Error != "boom" {
+		t.Errorf("State = %q, Error = %q, want failed with boom", rec.State, rec.Error)
+	}
+	if len(rec.Transitions) != 3 {
+		t.Errorf("len(Transitions) = %d, want 3 (final state is sticky)", len(rec.Transitions))
+	}
+	if rec.Priority != 3 {
+		t.Error("Priority should be recorded")
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	h := NewHistory(2)
+	h.Add(&Injection{ID: "a"})
+	h.Add(&Injection{ID: "b"})
+	h.Add(&Injection{ID: "c"})
+
+	if _, ok := h.Get("a"); ok {
+		t.Error("oldest record should be evicted")
+	}
+	if _, ok := h.Get("c"); !ok {
+		t.Error("newest record should be kept")
+	}
+}
+
+func TestHistoryGetReturnsCopy(t *testing.T) {
+	h := NewHistory(10)
+	h.Add(&Injection{ID: "a"})
+
+	rec, _ := h.Get("a")
+	rec.Transitions[0].State = StateCancelled
+
+	rec, _ = h.Get("a")
+	if rec.Transitions[0].State != StateQueued {
+		t.Error("Get() should return a copy")
+	}
+}
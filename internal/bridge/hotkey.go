@@ -0,0 +1,81 @@
+package bridge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// hotkey intercepts a single control byte in the local terminal's input so
+// aibridge can react to it without the child seeing it.
+type hotkey struct {
+	mu  sync.Mutex
+	key byte
+	fn  func()
+}
+
+// SetHotkey calls fn whenever key is typed in the local terminal. The key is
+// not passed on to the child. A zero key disables the hotkey.
+func (p *PTY) SetHotkey(key byte, fn func()) {
+	p.hotkey.mu.Lock()
+	defer p.hotkey.mu.Unlock()
+
+	p.hotkey.key = key
+	p.hotkey.fn = fn
+}
+
+// filter wraps r so that reads skip the hotkey byte and run its callback.
+func (h *hotkey) filter(r io.Reader) io.Reader {
+	return &hotkeyReader{r: r, h: h}
+}
+
+type hotkeyReader struct {
+	r io.Reader
+	h *hotkey
+}
+
+func (hr *hotkeyReader) Read(p []byte) (int, error) {
+	for {
+		n, err := hr.r.Read(p)
+
+		hr.h.mu.Lock()
+		key, fn := hr.h.key, hr.h.fn
+		hr.h.mu.Unlock()
+
+		if key != 0 && fn != nil && bytes.IndexByte(p[:n], key) >= 0 {
+			kept := p[:0]
+			for _, c := range p[:n] {
+				if c == key {
+					fn()
+					continue
+				}
+				kept = append(kept, c)
+			}
+			n = len(kept)
+		}
+		// Don't report an empty read for input that was all hotkeys.
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// ParseHotkey turns a name like "ctrl-]" or "ctrl-g" into its control byte.
+// "none" and the empty string disable the hotkey.
+func ParseHotkey(name string) (byte, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "none" {
+		return 0, nil
+	}
+	rest, ok := strings.CutPrefix(name, "ctrl-")
+	if !ok || len(rest) != 1 {
+		return 0, fmt.Errorf("invalid hotkey %q, expected ctrl-<key>", name)
+	}
+	c := strings.ToUpper(rest)[0]
+	if c < '@' || c > '_' {
+		return 0, fmt.Errorf("invalid hotkey %q, expected ctrl-<key>", name)
+	}
+	return c - '@', nil
+}
@@ -0,0 +1,48 @@
+package bridge
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseHotkey(t *testing.T) {
+	tests := []struct {
+		name string
+		want byte
+	}{
+		{"ctrl-]", 0x1d},
+		{"Ctrl-G", 0x07},
+		{"ctrl-a", 0x01},
+		{"none", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseHotkey(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseHotkey(%q) = %#x, %v, want %#x", tt.name, got, err, tt.want)
+		}
+	}
+
+	for _, name := range []string{"p", "ctrl-", "ctrl-ab", "ctrl-1", "alt-x"} {
+		if _, err := ParseHotkey(name); err == nil {
+			t.Errorf("ParseHotkey(%q) should fail", name)
+		}
+	}
+}
+
+func TestHotkeyFilter(t *testing.T) {
+	presses := 0
+	h := &hotkey{key: 0x1d, fn: func() { presses++ }}
+
+	data, err := io.ReadAll(h.filter(strings.NewReader("ab\x1dc\x1d\x1d")))
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != "abc" {
+		t.Errorf("filtered input = %q, want %q", data, "abc")
+	}
+	if presses != 3 {
+		t.Errorf("presses = %d, want 3", presses)
+	}
+}
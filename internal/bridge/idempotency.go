@@ -0,0 +1,78 @@
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const DefaultIdempotencyWindow = time.Hour
+
+// DuplicateError is returned by Enqueue when the idempotency key was already
+// used within the retention window. ID is the original injection.
+type DuplicateError struct {
+	ID string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate of injection %s", e.ID)
+}
+
+type idempotencyEntry struct {
+	key     string
+	id      string
+	expires time.Time
+}
+
+// IdempotencyStore remembers which injection each idempotency key produced
+// for a retention window.
+type IdempotencyStore struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]idempotencyEntry
+	// order holds keys by insertion, and so by expiry since the window is
+	// fixed.
+	order []string
+}
+
+func NewIdempotencyStore(window time.Duration) *IdempotencyStore {
+	if window <= 0 {
+		window = DefaultIdempotencyWindow
+	}
+	return &IdempotencyStore{
+		window:  window,
+		entries: make(map[string]idempotencyEntry),
+	}
+}
+
+// Do returns the injection ID recorded for key, if any. Otherwise it calls
+// enqueue and records the ID it returns on success. Concurrent calls with the
+// same key enqueue at most once.
+func (s *IdempotencyStore) Do(key string, enqueue func() (string, error)) (id string, duplicate bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+	if e, ok := s.entries[key]; ok {
+		return e.id, true, nil
+	}
+
+	id, err = enqueue()
+	if err != nil {
+		return "", false, err
+	}
+	s.entries[key] = idempotencyEntry{key: key, id: id, expires: now.Add(s.window)}
+	s.order = append(s.order, key)
+	return id, false, nil
+}
+
+// prune forgets keys whose window has passed. The caller must hold s.mu.
+func (s *IdempotencyStore) prune(now time.Time) {
+	n := 0
+	for n < len(s.order) && !s.entries[s.order[n]].expires.After(now) {
+		delete(s.entries, s.order[n])
+		n++
+	}
+	s.order = s.order[n:]
+}
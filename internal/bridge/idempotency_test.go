@@ -0,0 +1,57 @@
+package bridge
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	s := NewIdempotencyStore(20 * time.Millisecond)
+
+	calls := 0
+	enqueue := func() (string, error) {
+		calls++
+		return "id-" + strconv.Itoa(calls), nil
+	}
+
+	id, dup, err := s.Do("k", enqueue)
+	if err != nil || dup || id != "id-1" {
+		t.Fatalf("Do() = %q, %v, %v, want id-1", id, dup, err)
+	}
+	if id, dup, _ = s.Do("k", enqueue); !dup || id != "id-1" || calls != 1 {
+		t.Errorf("repeated Do() = %q, %v after %d calls, want duplicate id-1", id, dup, calls)
+	}
+
+	failed := errors.New("queue full")
+	if _, _, err := s.Do("other", func() (string, error) { return "", failed }); err != failed {
+		t.Errorf("Do() error = %v, want %v", err, failed)
+	}
+	if id, dup, _ = s.Do("other", enqueue); dup || id != "id-2" {
+		t.Errorf("Do() after a failure = %q, %v, want a fresh id-2", id, dup)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if id, dup, _ = s.Do("k", enqueue); dup || id != "id-3" {
+		t.Errorf("Do() after the window = %q, %v, want a fresh id-3", id, dup)
+	}
+}
+
+func TestEnqueueIdempotencyKey(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	inj, err := b.Enqueue("hello", EnqueueOptions{IdempotencyKey: "retry-1"})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	_, err = b.Enqueue("hello", EnqueueOptions{IdempotencyKey: "retry-1"})
+	var dup *DuplicateError
+	if !errors.As(err, &dup) || dup.ID != inj.ID {
+		t.Fatalf("Enqueue() error = %v, want duplicate of %s", err, inj.ID)
+	}
+	if b.Queue().Len() != 1 {
+		t.Errorf("Len() = %d, want 1", b.Queue().Len())
+	}
+}
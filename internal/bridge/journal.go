@@ -0,0 +1,435 @@
+package bridge
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+var errBridgeRestarted = errors.New("interrupted by bridge restart")
+
+// journalCompactLines is how many lines may be appended to the journal
+// before it is rewritten to just the injections that are not finished yet.
+const journalCompactLines = 1000
+
+type journalOp string
+
+const (
+	journalEnqueue journalOp = "enqueue"
+	journalEdit    journalOp = "edit"
+	journalState   journalOp = "state"
+	journalRemove  journalOp = "remove"
+	journalMove    journalOp = "move"
+)
+
+// journalEntry is one line of the queue journal.
+type journalEntry struct {
+	Op        journalOp      `json:"op"`
+	ID        string         `json:"id"`
+	Time      time.Time      `json:"time"`
+	Text      string         `json:"text,omitempty"`
+	Keys      []string       `json:"keys,omitempty"`
+	Priority  int            `json:"priority,omitempty"`
+	Source    string         `json:"source,omitempty"`
+	Key       string         `json:"key,omitempty"`
+	Batch     bool           `json:"batch,omitempty"`
+	Sequence  string         `json:"sequence,omitempty"`
+	NotBefore time.Time      `json:"not_before,omitzero"`
+	ExpiresAt time.Time      `json:"expires_at,omitzero"`
+	State     InjectionState `json:"state,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	// Before and After name the injection a moved one was placed next to.
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Journal appends queue changes to a JSONL file so pending injections can be
+// restored after a restart. A nil *Journal discards everything.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	failed  bool
+	verbose bool
+	// lines counts the lines appended since the journal was last compacted
+	// and base the lines the compaction kept.
+	lines int
+	base  int
+}
+
+// OpenJournal opens path for appending, creating it and its directory if
+// needed. Write failures are only logged when verbose is set, since the log
+// would otherwise land on the terminal the wrapped tool is drawing.
+func OpenJournal(path string, verbose bool) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{path: path, file: f, verbose: verbose}, nil
+}
+
+func (j *Journal) enqueue(inj *Injection) {
+	j.write(enqueueEntry(inj))
+}
+
+func enqueueEntry(inj *Injection) journalEntry {
+	return journalEntry{
+		Op:        journalEnqueue,
+		ID:        inj.ID,
+		Time:      inj.EnqueuedAt,
+		Text:      inj.Text,
+		Keys:      inj.Keys,
+		Priority:  inj.Priority,
+		Source:    inj.Source,
+		Key:       inj.Key,
+		Batch:     inj.Batch,
+		Sequence:  inj.Sequence,
+		NotBefore: inj.NotBefore,
+		ExpiresAt: inj.ExpiresAt,
+	}
+}
+
+func (j *Journal) edit(id, text string, priority int) {
+	j.write(journalEntry{Op: journalEdit, ID: id, Time: time.Now(), Text: text, Priority: priority})
+}
+
+func (j *Journal) state(id string, change StateChange) {
+	j.write(journalEntry{Op: journalState, ID: id, Time: change.Time, State: change.State, Error: change.Error})
+}
+
+func (j *Journal) remove(id string) {
+	j.write(journalEntry{Op: journalRemove, ID: id, Time: time.Now()})
+}
+
+func (j *Journal) move(id, before, after string) {
+	j.write(journalEntry{Op: journalMove, ID: id, Time: time.Now(), Before: before, After: after})
+}
+
+func (j *Journal) write(e journalEntry) {
+	if j == nil {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil && !j.failed {
+		j.failed = true
+		if j.verbose {
+			log.Printf("Failed to write queue journal: %v", err)
+		}
+	}
+	j.lines++
+	if j.lines > j.base+journalCompactLines {
+		j.compact()
+	}
+}
+
+// compact rewrites the journal to the injections that are still queued or
+// in flight, so it does not grow for the life of the process. The caller
+// must hold j.mu.
+func (j *Journal) compact() {
+	entries, err := readJournal(j.path)
+	if err != nil {
+		return
+	}
+	var kept []journalEntry
+	for _, r := range foldJournal(entries) {
+		if r.rec.State.Final() {
+			continue
+		}
+		kept = append(kept, enqueueEntry(r.inj))
+		if r.rec.State != StateQueued {
+			last := r.rec.Transitions[len(r.rec.Transitions)-1]
+			kept = append(kept, journalEntry{Op: journalState, ID: r.inj.ID, Time: last.Time, State: last.State, Error: last.Error})
+		}
+	}
+
+	// The file is closed first since Windows cannot replace an open file.
+	_ = j.file.Close()
+	err = writeJournal(j.path, kept)
+	f, openErr := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if openErr != nil {
+		j.file = nil
+		if j.verbose {
+			log.Printf("Failed to reopen queue journal: %v", openErr)
+		}
+		return
+	}
+	j.file = f
+	if err == nil {
+		j.lines = len(kept)
+		j.base = len(kept)
+	}
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// readJournal returns the entries in path. A missing file yields no
+// entries, and lines that fail to parse, such as one cut short by a crash,
+// are skipped.
+func readJournal(path string) ([]journalEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []journalEntry
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var e journalEntry
+			if json.Unmarshal(line, &e) == nil && e.ID != "" {
+				entries = append(entries, e)
+			}
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeJournal atomically replaces path with entries.
+func writeJournal(path string, entries []journalEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// restoreQueue replays the journal at path: injections that were still
+// queued go back into the queue, ones that were being typed or answered are
+// marked failed, and all of them reappear in the history. The journal is
+// then compacted to the pending injections and kept open for appending.
+func (b *Bridge) restoreQueue(path string) error {
+	entries, err := readJournal(path)
+	if err != nil {
+		return fmt.Errorf("failed to read queue journal: %w", err)
+	}
+
+	var queued []*Injection
+	for _, r := range foldJournal(entries) {
+		b.history.restore(r.rec)
+
+		switch {
+		case r.rec.State == StateQueued:
+			queued = append(queued, r.inj)
+		case !r.rec.State.Final():
+			b.history.Update(r.inj.ID, StateFailed, errBridgeRestarted)
+		}
+	}
+
+	var pending []journalEntry
+	for len(queued) > 0 {
+		// Consecutive injections of the same sequence go back as one.
+		n := 1
+		for n < len(queued) && queued[0].Sequence != "" && queued[n].Sequence == queued[0].Sequence {
+			n++
+		}
+		group := queued[:n]
+		queued = queued[n:]
+
+		var err error
+		if group[0].Sequence != "" {
+			err = b.queue.EnqueueSequence(group)
+		} else {
+			_, err = b.queue.EnqueueInjection(group[0])
+		}
+		for _, inj := range group {
+			if err != nil {
+				b.history.Update(inj.ID, StateFailed, err)
+				continue
+			}
+			pending = append(pending, enqueueEntry(inj))
+		}
+	}
+
+	if err := writeJournal(path, pending); err != nil {
+		return fmt.Errorf("failed to compact queue journal: %w", err)
+	}
+	journal, err := OpenJournal(path, b.verbose)
+	if err != nil {
+		return fmt.Errorf("failed to open queue journal: %w", err)
+	}
+	b.journal = journal
+	b.history.SetJournal(journal)
+	b.queue.SetJournal(journal)
+	return nil
+}
+
+// restoredInjection is an injection and its record as rebuilt from the
+// journal.
+type restoredInjection struct {
+	inj *Injection
+	rec *InjectionRecord
+}
+
+// foldJournal replays entries and returns the injections they describe in
+// queue order, leaving out removed ones.
+func foldJournal(entries []journalEntry) []restoredInjection {
+	byID := make(map[string]*restoredInjection)
+	var order []string
+
+	for _, e := range entries {
+		r := byID[e.ID]
+		switch e.Op {
+		case journalEnqueue:
+			if r != nil {
+				continue
+			}
+			byID[e.ID] = &restoredInjection{
+				inj: &Injection{
+					ID:         e.ID,
+					Text:       e.Text,
+					Keys:       e.Keys,
+					Priority:   e.Priority,
+					Source:     e.Source,
+					Key:        e.Key,
+					Batch:      e.Batch,
+					Sequence:   e.Sequence,
+					EnqueuedAt: e.Time,
+					NotBefore:  e.NotBefore,
+					ExpiresAt:  e.ExpiresAt,
+				},
+				rec: &InjectionRecord{
+					ID:          e.ID,
+					Priority:    e.Priority,
+					State:       StateQueued,
+					CreatedAt:   e.Time,
+					UpdatedAt:   e.Time,
+					Transitions: []StateChange{{State: StateQueued, Time: e.Time}},
+				},
+			}
+			// An injection that superseded a queued one with the same key
+			// took over its place.
+			pos := len(order)
+			for i, id := range order {
+				if o := byID[id]; o != nil && e.Key != "" && o.inj.Key == e.Key && o.rec.State == StateQueued {
+					pos = i + 1
+				}
+			}
+			order = slices.Insert(order, pos, e.ID)
+		case journalEdit:
+			if r != nil {
+				r.inj.Text = e.Text
+				r.inj.Priority = e.Priority
+				r.rec.Priority = e.Priority
+			}
+		case journalState:
+			if r != nil {
+				r.rec.apply(StateChange{State: e.State, Time: e.Time, Error: e.Error})
+			}
+		case journalRemove:
+			delete(byID, e.ID)
+		case journalMove:
+			if r == nil {
+				continue
+			}
+			start, end := journalBlock(order, byID, e.ID)
+			moved := slices.Clone(order[start:end])
+			order = slices.Delete(order, start, end)
+			pos := len(order)
+			if i, _ := journalBlock(order, byID, e.Before); e.Before != "" && i >= 0 {
+				pos = i
+			} else if _, j := journalBlock(order, byID, e.After); e.After != "" && j >= 0 {
+				pos = j
+			}
+			order = slices.Insert(order, pos, moved...)
+		}
+	}
+
+	var result []restoredInjection
+	for _, id := range order {
+		if r, ok := byID[id]; ok {
+			result = append(result, *r)
+		}
+	}
+	return result
+}
+
+// journalBlock returns the range of order taken by id, which spans its whole
+// sequence if it has one, or -1, -1 if id is not in order.
+func journalBlock(order []string, byID map[string]*restoredInjection, id string) (int, int) {
+	start := slices.Index(order, id)
+	if start < 0 {
+		return -1, -1
+	}
+	end := start + 1
+	r := byID[id]
+	if r == nil || r.inj.Sequence == "" {
+		return start, end
+	}
+	inSequence := func(other string) bool {
+		o := byID[other]
+		return o != nil && o.inj.Sequence == r.inj.Sequence
+	}
+	for start > 0 && inSequence(order[start-1]) {
+		start--
+	}
+	for end < len(order) && inSequence(order[end]) {
+		end++
+	}
+	return start, end
+}
@@ -0,0 +1,221 @@
+package bridge
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestJournalRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "queue.jsonl")
+
+	b := newTestBridge(t, Options{JournalPath: path})
+
+	inflight, _ := b.Enqueue("in flight", EnqueueOptions{})
+	cancelled, _ := b.Enqueue("cancelled", EnqueueOptions{})
+	edited, _ := b.Enqueue("original", EnqueueOptions{Source: "mobai"})
+	pending, _ := b.Enqueue("pending", EnqueueOptions{Priority: 2})
+
+	b.queue.Dequeue()
+	b.history.Update(inflight.ID, StateInjecting, nil)
+	b.CancelInjection(cancelled.ID)
+	text := "edited"
+	if _, err := b.UpdateInjection(edited.ID, QueueUpdate{Text: &text}); err != nil {
+		t.Fatalf("UpdateInjection failed: %v", err)
+	}
+	_ = b.journal.Close()
+
+	// Simulate a crash in the middle of writing a line.
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	_, _ = f.WriteString(`{"op":"enqueue","id":"torn`)
+	f.Close()
+
+	b2 := newTestBridge(t, Options{JournalPath: path})
+
+	items := b2.Queue().Snapshot()
+	if len(items) != 2 {
+		t.Fatalf("restored %d items, want 2", len(items))
+	}
+	if items[0].ID != pending.ID || items[0].Priority != 2 {
+		t.Errorf("items[0] = %+v, want %s at priority 2", items[0], pending.ID)
+	}
+	if items[1].ID != edited.ID || items[1].Text != "edited" || items[1].Source != "mobai" {
+		t.Errorf("items[1] = %+v, want edited text from mobai", items[1])
+	}
+	if !items[1].EnqueuedAt.Equal(edited.EnqueuedAt) {
+		t.Errorf("EnqueuedAt = %v, want %v", items[1].EnqueuedAt, edited.EnqueuedAt)
+	}
+
+	if rec, _ := b2.Injection(inflight.ID); rec.State != StateFailed || rec.Error != errBridgeRestarted.Error() {
+		t.Errorf("in-flight record = %q (%q), want failed by restart", rec.State, rec.Error)
+	}
+	if rec, _ := b2.Injection(cancelled.ID); rec.State != StateCancelled {
+		t.Errorf("cancelled record = %q, want %q", rec.State, StateCancelled)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Errorf("compacted journal has %d lines, want 2:\n%s", n, data)
+	}
+}
+
+func TestJournalNil(t *testing.T) {
+	var j *Journal
+	j.enqueue(&Injection{ID: "a"})
+	if err := j.Close(); err != nil {
+		t.Errorf("Close() on nil journal = %v", err)
+	}
+}
+
+func TestJournalRestoreKeyed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+
+	b := newTestBridge(t, Options{JournalPath: path})
+	b.Enqueue("screen 1", EnqueueOptions{Key: "screen"})
+	first, _ := b.Enqueue("first", EnqueueOptions{})
+	screen, _ := b.Enqueue("screen 2", EnqueueOptions{Key: "screen"})
+	_ = b.journal.Close()
+
+	b2 := newTestBridge(t, Options{JournalPath: path})
+
+	items := b2.Queue().Snapshot()
+	if len(items) != 2 || items[0].ID != screen.ID || items[1].ID != first.ID {
+		t.Fatalf("restored %+v, want %s then %s", items, screen.ID, first.ID)
+	}
+	if items[0].Key != "screen" {
+		t.Errorf("Key = %q, want screen", items[0].Key)
+	}
+
+	// The key still coalesces after the restart.
+	if inj, _ := b2.Enqueue("screen 3", EnqueueOptions{Key: "screen"}); inj.Replaced != screen.ID {
+		t.Errorf("Replaced = %q, want %q", inj.Replaced, screen.ID)
+	}
+}
+
+func TestJournalRestoreSequence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+
+	b := newTestBridge(t, Options{JournalPath: path})
+	injs, err := b.EnqueueSequence([]string{"first", "second"}, EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("EnqueueSequence failed: %v", err)
+	}
+	_ = b.journal.Close()
+
+	b2 := newTestBridge(t, Options{JournalPath: path})
+
+	if inj := b2.queue.Dequeue(); inj == nil || inj.ID != injs[0].ID {
+		t.Fatalf("Dequeue() = %v, want the start of the restored sequence", inj)
+	}
+	b2.Enqueue("urgent", EnqueueOptions{Priority: MaxPriority})
+	if inj := b2.queue.Dequeue(); inj == nil || inj.ID != injs[1].ID {
+		t.Errorf("Dequeue() = %v, want the rest of the restored sequence", inj)
+	}
+}
+
+func TestJournalCompactsAtRuntime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+
+	b := newTestBridge(t, Options{JournalPath: path})
+	pending, _ := b.Enqueue("pending", EnqueueOptions{})
+	inflight, _ := b.Enqueue("in flight", EnqueueOptions{Priority: 1})
+	b.queue.Dequeue()
+	b.history.Update(inflight.ID, StateInjecting, nil)
+	for i := 0; i < journalCompactLines; i++ {
+		inj, _ := b.Enqueue("noise", EnqueueOptions{})
+		b.CancelInjection(inj.ID)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if n := strings.Count(string(data), "\n"); n > journalCompactLines {
+		t.Fatalf("journal has %d lines, want it compacted", n)
+	}
+	_ = b.journal.Close()
+
+	b2 := newTestBridge(t, Options{JournalPath: path})
+	if items := b2.Queue().Snapshot(); len(items) != 1 || items[0].ID != pending.ID {
+		t.Errorf("restored %+v, want %s", items, pending.ID)
+	}
+	if rec, _ := b2.Injection(inflight.ID); rec.State != StateFailed {
+		t.Errorf("in-flight record = %q, want failed by restart", rec.State)
+	}
+}
+
+func TestJournalEvictedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+
+	b := newTestBridge(t, Options{JournalPath: path})
+	b.history.size = 1
+	first, _ := b.Enqueue("first", EnqueueOptions{})
+	second, _ := b.Enqueue("second", EnqueueOptions{})
+	if _, ok := b.Injection(first.ID); ok {
+		t.Fatal("the first record should have been evicted")
+	}
+
+	text := "edited"
+	if _, err := b.UpdateInjection(first.ID, QueueUpdate{Text: &text}); err != nil {
+		t.Fatalf("UpdateInjection failed: %v", err)
+	}
+	b.queue.Dequeue()
+	b.history.Update(first.ID, StateInjecting, nil)
+	b.history.Update(first.ID, StateCompleted, nil)
+	_ = b.journal.Close()
+
+	b2 := newTestBridge(t, Options{JournalPath: path})
+	if items := b2.Queue().Snapshot(); len(items) != 1 || items[0].ID != second.ID {
+		t.Errorf("restored %+v, want only %s", items, second.ID)
+	}
+	if rec, _ := b2.Injection(first.ID); rec.State != StateCompleted {
+		t.Errorf("State = %q, want the completed injection not queued again", rec.State)
+	}
+}
+
+func TestJournalRestoreMoves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+
+	b := newTestBridge(t, Options{JournalPath: path})
+	a, _ := b.Enqueue("a", EnqueueOptions{})
+	d, _ := b.Enqueue("d", EnqueueOptions{})
+	seq, _ := b.EnqueueSequence([]string{"s1", "s2"}, EnqueueOptions{})
+	c, _ := b.Enqueue("c", EnqueueOptions{})
+
+	front, end := 0, 4
+	if _, err := b.UpdateInjection(c.ID, QueueUpdate{Position: &front}); err != nil {
+		t.Fatalf("UpdateInjection failed: %v", err)
+	}
+	if _, err := b.UpdateInjection(a.ID, QueueUpdate{Position: &end}); err != nil {
+		t.Fatalf("UpdateInjection failed: %v", err)
+	}
+	// Preempting moves a whole sequence to the front.
+	if err := b.queue.MoveToFront(seq[0].ID); err != nil {
+		t.Fatalf("MoveToFront failed: %v", err)
+	}
+	for _, inj := range seq {
+		b.history.Edit(inj.ID, inj.Text, MaxPriority)
+	}
+	want := []string{seq[0].ID, seq[1].ID, c.ID, d.ID, a.ID}
+	ids := func(q *Queue) []string {
+		var ids []string
+		for _, inj := range q.Snapshot() {
+			ids = append(ids, inj.ID)
+		}
+		return ids
+	}
+	if got := ids(b.Queue()); !slices.Equal(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+	_ = b.journal.Close()
+
+	b2 := newTestBridge(t, Options{JournalPath: path})
+	if got := ids(b2.Queue()); !slices.Equal(got, want) {
+		t.Errorf("restored order = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package bridge
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namedKeys maps key names to the bytes a terminal sends for them. Cursor
+// keys are listed in normal mode and switched to SS3 form when the
+// application enables application cursor keys.
+var namedKeys = map[string]string{
+	"enter":     "\r",
+	"return":    "\r",
+	"tab":       "\t",
+	"shift-tab": "\x1b[Z",
+	"backtab":   "\x1b[Z",
+	"esc":       "\x1b",
+	"escape":    "\x1b",
+	"space":     " ",
+	"backspace": "\x7f",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"home":      "\x1b[H",
+	"end":       "\x1b[F",
+	"insert":    "\x1b[2~",
+	"delete":    "\x1b[3~",
+	"pageup":    "\x1b[5~",
+	"pagedown":  "\x1b[6~",
+	"f1":        "\x1bOP",
+	"f2":        "\x1bOQ",
+	"f3":        "\x1bOR",
+	"f4":        "\x1bOS",
+	"f5":        "\x1b[15~",
+	"f6":        "\x1b[17~",
+	"f7":        "\x1b[18~",
+	"f8":        "\x1b[19~",
+	"f9":        "\x1b[20~",
+	"f10":       "\x1b[21~",
+	"f11":       "\x1b[23~",
+	"f12":       "\x1b[24~",
+}
+
+// ValidateKeys checks that every name in keys can be encoded.
+func ValidateKeys(keys []string) error {
+	_, err := encodeKeys(keys, false)
+	return err
+}
+
+// encodeKeys turns key names such as "esc", "ctrl-c", "shift-tab" or
+// "alt-x" into the bytes a terminal would send. A single character stands
+// for itself.
+func encodeKeys(keys []string, appCursor bool) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("no keys given")
+	}
+
+	var b strings.Builder
+	for _, key := range keys {
+		seq, err := encodeKey(key, appCursor)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(seq)
+	}
+	return b.String(), nil
+}
+
+func encodeKey(key string, appCursor bool) (string, error) {
+	if len([]rune(key)) == 1 {
+		return key, nil
+	}
+	key = strings.ReplaceAll(strings.TrimSpace(key), "+", "-")
+	name := strings.ToLower(key)
+
+	if seq, ok := namedKeys[name]; ok {
+		if appCursor && len(seq) == 3 && seq[1] == '[' && strings.ContainsRune("ABCDHF", rune(seq[2])) {
+			seq = "\x1bO" + seq[2:]
+		}
+		return seq, nil
+	}
+	if strings.HasPrefix(name, "alt-") {
+		seq, err := encodeKey(key[len("alt-"):], appCursor)
+		if err != nil {
+			return "", err
+		}
+		return "\x1b" + seq, nil
+	}
+	if rest, ok := strings.CutPrefix(name, "ctrl-"); ok {
+		if rest == "space" {
+			return "\x00", nil
+		}
+		if c, err := ParseHotkey(name); err == nil && c != 0 {
+			return string(rune(c)), nil
+		}
+	}
+	return "", fmt.Errorf("unknown key %q", key)
+}
+
+// SendKeys types the named keys into the child.
+func (p *PTY) SendKeys(keys []string) error {
+	seq, err := encodeKeys(keys, p.screen.AppCursorKeys())
+	if err != nil {
+		return err
+	}
+	_, err = p.Write([]byte(seq))
+	return err
+}
@@ -0,0 +1,54 @@
+package bridge
+
+import "testing"
+
+func TestEncodeKeys(t *testing.T) {
+	tests := []struct {
+		keys      []string
+		appCursor bool
+		want      string
+	}{
+		{[]string{"Esc"}, false, "\x1b"},
+		{[]string{"ctrl-c"}, false, "\x03"},
+		{[]string{"Ctrl+C"}, false, "\x03"},
+		{[]string{"shift-tab"}, false, "\x1b[Z"},
+		{[]string{"down", "down", "enter"}, false, "\x1b[B\x1b[B\r"},
+		{[]string{"up"}, true, "\x1bOA"},
+		{[]string{"pageup"}, true, "\x1b[5~"},
+		{[]string{"alt-x"}, false, "\x1bx"},
+		{[]string{"alt-left"}, false, "\x1b\x1b[D"},
+		{[]string{"1", "y"}, false, "1y"},
+	}
+	for _, tt := range tests {
+		got, err := encodeKeys(tt.keys, tt.appCursor)
+		if err != nil || got != tt.want {
+			t.Errorf("encodeKeys(%q, %v) = %q, %v, want %q", tt.keys, tt.appCursor, got, err, tt.want)
+		}
+	}
+
+	for _, keys := range [][]string{nil, {"hyper-x"}, {"ctrl-1"}, {"enter", "nope"}} {
+		if err := ValidateKeys(keys); err == nil {
+			t.Errorf("ValidateKeys(%q) should fail", keys)
+		}
+	}
+}
+
+func TestEnqueueKeys(t *testing.T) {
+	b := newTestBridge(t, Options{Batch: true})
+
+	if _, err := b.EnqueueKeys([]string{"nope"}, EnqueueOptions{}); err == nil {
+		t.Error("EnqueueKeys() with an unknown key should fail")
+	}
+
+	b.Enqueue("text", EnqueueOptions{})
+	inj, err := b.EnqueueKeys([]string{"shift-tab"}, EnqueueOptions{})
+	if err != nil {
+		t.Fatalf("EnqueueKeys failed: %v", err)
+	}
+	if inj.Batch {
+		t.Error("key injections should never be batched")
+	}
+	if batch := b.queue.DequeueBatch(); len(batch) != 1 {
+		t.Errorf("DequeueBatch() returned %d items, want the text alone", len(batch))
+	}
+}
@@ -0,0 +1,130 @@
+package bridge
+
+import (
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[P^_X][^\x1b]*\x1b\\|\x1b[ -/]*[0-~]`)
+
+var partialEscapeRegex = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*|\][^\x07\x1b]*|[P^_X][^\x1b]*|[ -/]*)$`)
+
+func StripANSI(s string) string {
+	return ansiRegex.ReplaceAllString(s, "")
+}
+
+// OutputBuffer keeps the most recent child output in a ring buffer.
+// Offsets count every byte ever written, so they keep increasing after old
+// output has been overwritten and clients can resume from where they left.
+type OutputBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int64
+	end  int64
+}
+
+type OutputChunk struct {
+	Data      []byte
+	Start     int64
+	Offset    int64
+	Next      int64
+	End       int64
+	Truncated bool
+}
+
+func NewOutputBuffer(size int) *OutputBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &OutputBuffer{
+		buf:  make([]byte, size),
+		size: int64(size),
+	}
+}
+
+func (o *OutputBuffer) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	n := len(p)
+	if int64(n) > o.size {
+		o.end += int64(n) - o.size
+		p = p[int64(n)-o.size:]
+	}
+
+	pos := o.end % o.size
+	c := copy(o.buf[pos:], p)
+	copy(o.buf, p[c:])
+	o.end += int64(len(p))
+	return n, nil
+}
+
+func (o *OutputBuffer) End() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.end
+}
+
+// Read returns up to limit bytes starting at offset since. A negative since
+// returns the last limit bytes. Reads never stop inside a UTF-8 rune or an
+// escape sequence unless that would return nothing; the remainder is
+// returned by the next read.
+func (o *OutputBuffer) Read(since int64, limit int) OutputChunk {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	start := max(o.end-o.size, 0)
+	chunk := OutputChunk{Start: start, End: o.end}
+
+	if limit <= 0 || int64(limit) > o.size {
+		limit = int(o.size)
+	}
+	if since < 0 {
+		since = max(o.end-int64(limit), start)
+	}
+	if since < start {
+		since = start
+		chunk.Truncated = true
+	}
+	since = min(since, o.end)
+
+	to := min(since+int64(limit), o.end)
+	data := make([]byte, 0, to-since)
+	for off := since; off < to; {
+		pos := off % o.size
+		n := min(to-off, o.size-pos)
+		data = append(data, o.buf[pos:pos+n]...)
+		off += n
+	}
+
+	if n := completePrefix(data); n > 0 {
+		data = data[:n]
+	}
+	chunk.Data = data
+	chunk.Offset = since
+	chunk.Next = since + int64(len(data))
+	return chunk
+}
+
+func completePrefix(data []byte) int {
+	n := len(data)
+
+	tail := n - 256
+	if tail < 0 {
+		tail = 0
+	}
+	if loc := partialEscapeRegex.FindIndex(data[tail:]); loc != nil {
+		n = tail + loc[0]
+	}
+
+	for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:n]) {
+				n = i
+			}
+			break
+		}
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package bridge
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOutputBufferRead(t *testing.T) {
+	o := NewOutputBuffer(16)
+	_, _ = o.Write([]byte("hello "))
+	_, _ = o.Write([]byte("world"))
+
+	chunk := o.Read(0, 0)
+	if string(chunk.Data) != "hello world" {
+		t.Errorf("Data = %q, want %q", chunk.Data, "hello world")
+	}
+	if chunk.Next != 11 || chunk.End != 11 {
+		t.Errorf("Next = %d, End = %d, want 11, 11", chunk.Next, chunk.End)
+	}
+
+	chunk = o.Read(6, 3)
+	if string(chunk.Data) != "wor" || chunk.Next != 9 {
+		t.Errorf("Read(6, 3) = %q next %d, want %q next 9", chunk.Data, chunk.Next, "wor")
+	}
+
+	chunk = o.Read(chunk.Next, 0)
+	if string(chunk.Data) != "ld" {
+		t.Errorf("Data = %q, want %q", chunk.Data, "ld")
+	}
+}
+
+func TestOutputBufferWrapAround(t *testing.T) {
+	o := NewOutputBuffer(8)
+	_, _ = o.Write([]byte("0123456789"))
+	_, _ = o.Write([]byte("ab"))
+
+	chunk := o.Read(0, 0)
+	if !chunk.Truncated {
+		t.Error("Read from an overwritten offset should be truncated")
+	}
+	if chunk.Start != 4 || chunk.Offset != 4 {
+		t.Errorf("Start = %d, Offset = %d, want 4, 4", chunk.Start, chunk.Offset)
+	}
+	if string(chunk.Data) != "456789ab" {
+		t.Errorf("Data = %q, want %q", chunk.Data, "456789ab")
+	}
+}
+
+func TestOutputBufferTail(t *testing.T) {
+	o := NewOutputBuffer(64)
+	_, _ = o.Write([]byte("line one\nline two\n"))
+
+	chunk := o.Read(-1, 9)
+	if string(chunk.Data) != "line two\n" || chunk.Offset != 9 {
+		t.Errorf("tail = %q at %d, want %q at 9", chunk.Data, chunk.Offset, "line two\n")
+	}
+}
+
+func TestOutputBufferPartialSequences(t *testing.T) {
+	o := NewOutputBuffer(64)
+	_, _ = o.Write([]byte("ok\x1b[3"))
+
+	chunk := o.Read(0, 0)
+	if string(chunk.Data) != "ok" || chunk.Next != 2 {
+		t.Errorf("Data = %q next %d, want %q next 2", chunk.Data, chunk.Next, "ok")
+	}
+
+	_, _ = o.Write([]byte("1mé"))
+	chunk = o.Read(chunk.Next, 6)
+	if !bytes.Equal(chunk.Data, []byte("\x1b[31m")) {
+		t.Errorf("Data = %q, want the completed escape without a split rune", chunk.Data)
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	got := StripANSI("\x1b[1;32mhello\x1b[0m \x1b]0;title\x07world\x1b[?25l\x1b(B")
+	if got != "hello world" {
+		t.Errorf("StripANSI() = %q, want %q", got, "hello world")
+	}
+}
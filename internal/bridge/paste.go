@@ -0,0 +1,47 @@
+package bridge
+
+import "strings"
+
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// SetNewline sets the key sequence typed for line breaks in injected text
+// when the child has not enabled bracketed paste. Empty types them as-is.
+func (p *PTY) SetNewline(newline string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.newline = newline
+}
+
+// encodeText prepares multi-line text so its line breaks do not submit a
+// partial prompt. With bracketed paste the text is sent as a single paste;
+// otherwise each line break is typed as newline.
+func encodeText(text string, bracketed bool, newline string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if !strings.Contains(text, "\n") {
+		return text
+	}
+	if bracketed {
+		// Text containing the end marker would escape the paste.
+		return pasteStart + strings.ReplaceAll(text, pasteEnd, "") + pasteEnd
+	}
+	if newline == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", newline)
+}
+
+// echoNeedle returns the part of text that is looked for in the output to
+// detect its echo: the end of its last non-empty line, since tools redraw
+// multi-line input rather than echoing line breaks.
+func echoNeedle(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\r\n"), "\n")
+	needle := strings.TrimSpace(lines[len(lines)-1])
+	if len(needle) > 20 {
+		needle = needle[len(needle)-20:]
+	}
+	return needle
+}
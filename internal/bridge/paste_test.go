@@ -0,0 +1,40 @@
+package bridge
+
+import "testing"
+
+func TestEncodeText(t *testing.T) {
+	tests := []struct {
+		text      string
+		bracketed bool
+		newline   string
+		want      string
+	}{
+		{"one line", true, "\\\r", "one line"},
+		{"a\nb", true, "\\\r", "\x1b[200~a\nb\x1b[201~"},
+		{"a\r\nb", true, "", "\x1b[200~a\nb\x1b[201~"},
+		{"a\n\x1b[201~b", true, "", "\x1b[200~a\nb\x1b[201~"},
+		{"a\nb", false, "\\\r", "a\\\rb"},
+		{"a\nb", false, "", "a\nb"},
+	}
+	for _, tt := range tests {
+		if got := encodeText(tt.text, tt.bracketed, tt.newline); got != tt.want {
+			t.Errorf("encodeText(%q, %v, %q) = %q, want %q", tt.text, tt.bracketed, tt.newline, got, tt.want)
+		}
+	}
+}
+
+func TestEchoNeedle(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"short", "short"},
+		{"first line\n  last line  \n", "last line"},
+		{"a prompt longer than twenty bytes", "er than twenty bytes"},
+	}
+	for _, tt := range tests {
+		if got := echoNeedle(tt.text); got != tt.want {
+			t.Errorf("echoNeedle(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
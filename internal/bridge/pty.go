@@ -13,6 +13,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/MobAI-App/aibridge/internal/vterm"
 	"github.com/creack/pty"
 	"golang.org/x/term"
 )
@@ -20,9 +21,16 @@ import (
 type PTY struct {
 	cmd      *exec.Cmd
 	ptmx     *os.File
+	screen   *vterm.Terminal
 	mu       sync.Mutex
 	closed   bool
 	oldState *term.State
+	hotkey   hotkey
+	newline  string
+	headless bool
+	cols     int
+	rows     int
+	stdout   io.Writer
 
 	echoMu        sync.Mutex
 	echoWaiting   string
@@ -35,38 +43,36 @@ func NewPTY(command string, args []string, injectDelayMs int) *PTY {
 	cmd := exec.Command(command, args...)
 	return &PTY{
 		cmd:           cmd,
+		screen:        vterm.New(vterm.DefaultCols, vterm.DefaultRows),
+		stdout:        os.Stdout,
 		injectDelayMs: injectDelayMs,
 	}
 }
 
-func (p *PTY) Start(outputCallback func(line string)) error {
-	ptmx, err := pty.Start(p.cmd)
-	if err != nil {
-		return err
-	}
-	p.ptmx = ptmx
-
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
-	if err != nil {
-		return err
-	}
-	p.oldState = oldState
-
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGWINCH)
-	go func() {
-		for range ch {
-			if p.closed {
-				return
-			}
-			_ = pty.InheritSize(os.Stdin, p.ptmx)
+func (p *PTY) Start(outputCallback func(data []byte)) error {
+	if p.headless {
+		// Services often run without TERM; the screen model speaks xterm.
+		if os.Getenv("TERM") == "" {
+			p.cmd.Env = append(os.Environ(), "TERM=xterm-256color")
 		}
-	}()
-	ch <- syscall.SIGWINCH
+		ptmx, err := pty.StartWithSize(p.cmd, &pty.Winsize{Cols: uint16(p.cols), Rows: uint16(p.rows)})
+		if err != nil {
+			return err
+		}
+		p.ptmx = ptmx
+	} else {
+		ptmx, err := pty.Start(p.cmd)
+		if err != nil {
+			return err
+		}
+		p.ptmx = ptmx
+		if err := p.attachTerminal(); err != nil {
+			return err
+		}
+	}
 
 	go func() {
 		reader := bufio.NewReader(p.ptmx)
-		lineBuffer := make([]byte, 0, 1024)
 
 		buf := make([]byte, 4096)
 		for {
@@ -75,69 +81,116 @@ func (p *PTY) Start(outputCallback func(line string)) error {
 				return
 			}
 
-			_, _ = os.Stdout.Write(buf[:n])
+			_, _ = p.stdout.Write(buf[:n])
 
 			p.checkEcho(string(buf[:n]))
 
-			for i := 0; i < n; i++ {
-				b := buf[i]
-				if b == '\n' || b == '\r' {
-					if len(lineBuffer) > 0 {
-						outputCallback(string(lineBuffer))
-						lineBuffer = lineBuffer[:0]
-					}
-				} else {
-					lineBuffer = append(lineBuffer, b)
-				}
-			}
-
-			if len(lineBuffer) > 0 {
-				outputCallback(string(lineBuffer))
-			}
+			outputCallback(buf[:n])
 		}
 	}()
 
+	if !p.headless {
+		go func() {
+			_, _ = io.Copy(p.ptmx, p.hotkey.filter(os.Stdin))
+		}()
+	}
+
+	return nil
+}
+
+// attachTerminal puts the local terminal into raw mode and keeps the child's
+// size in sync with it.
+func (p *PTY) attachTerminal() error {
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	p.oldState = oldState
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGWINCH)
 	go func() {
-		_, _ = io.Copy(p.ptmx, os.Stdin)
+		for range ch {
+			if p.closed {
+				return
+			}
+			_ = pty.InheritSize(os.Stdin, p.ptmx)
+			if rows, cols, err := pty.Getsize(p.ptmx); err == nil {
+				p.screen.Resize(cols, rows)
+			}
+		}
 	}()
+	ch <- syscall.SIGWINCH
 
 	return nil
 }
 
-func (p *PTY) InjectText(text string, sendEnter bool) error {
+// InjectText types text into the child and, when sendEnter is set, waits
+// for the text to be echoed before pressing Enter. It reports whether the
+// echo was seen.
+func (p *PTY) InjectText(text string, sendEnter bool) (bool, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.closed {
-		return io.ErrClosedPipe
+		return false, io.ErrClosedPipe
 	}
 
+	typed := encodeText(text, p.screen.BracketedPaste(), p.newline)
 	if sendEnter {
 		p.echoMu.Lock()
-		p.echoWaiting = text
+		p.echoWaiting = echoNeedle(text)
 		p.echoCh = make(chan struct{})
 		ch := p.echoCh
 		p.echoMu.Unlock()
 
-		_, err := p.ptmx.WriteString(text)
+		_, err := p.ptmx.WriteString(typed)
 		if err != nil {
 			p.clearEchoWait()
-			return err
+			return false, err
 		}
 
+		echoed := false
 		select {
 		case <-ch:
+			echoed = true
 			time.Sleep(time.Duration(p.injectDelayMs) * time.Millisecond)
 		case <-time.After(2 * time.Second):
 		}
 		p.clearEchoWait()
 
 		_, err = p.ptmx.Write([]byte{'\r'})
-		return err
+		return echoed, err
+	}
+
+	_, err := p.ptmx.WriteString(typed)
+	return false, err
+}
+
+// Write sends raw input, such as keystrokes from an attached client, to the
+// child alongside the local terminal's stdin.
+func (p *PTY) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, io.ErrClosedPipe
 	}
+	return p.ptmx.Write(data)
+}
 
-	_, err := p.ptmx.WriteString(text)
-	return err
+func (p *PTY) Resize(cols, rows int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return io.ErrClosedPipe
+	}
+	if err := pty.Setsize(p.ptmx, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)}); err != nil {
+		return err
+	}
+	p.screen.Resize(cols, rows)
+	return nil
 }
 
 func (p *PTY) clearEchoWait() {
@@ -157,11 +210,7 @@ func (p *PTY) checkEcho(data string) {
 	}
 
 	p.echoBuf += data
-	needle := p.echoWaiting
-	if len(needle) > 20 {
-		needle = needle[len(needle)-20:]
-	}
-	if strings.Contains(p.echoBuf, needle) {
+	if strings.Contains(p.echoBuf, p.echoWaiting) {
 		close(p.echoCh)
 		p.echoWaiting = ""
 		p.echoBuf = ""
@@ -169,10 +218,21 @@ func (p *PTY) checkEcho(data string) {
 	}
 }
 
+func (p *PTY) Screen() *vterm.Terminal {
+	return p.screen
+}
+
 func (p *PTY) Wait() error {
 	return p.cmd.Wait()
 }
 
+func (p *PTY) ExitCode() int {
+	if p.cmd.ProcessState == nil {
+		return -1
+	}
+	return p.cmd.ProcessState.ExitCode()
+}
+
 func (p *PTY) Close() error {
 	p.mu.Lock()
 	p.closed = true
@@ -11,27 +11,48 @@ import (
 	"sync"
 	"time"
 
+	"github.com/MobAI-App/aibridge/internal/vterm"
 	"github.com/UserExistsError/conpty"
 )
 
+const (
+	conptyCols = 120
+	conptyRows = 40
+)
+
 type PTY struct {
 	cmd           *exec.Cmd
 	cpty          *conpty.ConPty
+	screen        *vterm.Terminal
 	mu            sync.Mutex
 	closed        bool
+	exitCode      int
 	injectDelayMs int
+	hotkey        hotkey
+	newline       string
+	headless      bool
+	cols          int
+	rows          int
+	stdout        io.Writer
 }
 
 func NewPTY(command string, args []string, injectDelayMs int) *PTY {
 	cmd := exec.Command(command, args...)
 	return &PTY{
 		cmd:           cmd,
+		exitCode:      -1,
+		screen:        vterm.New(conptyCols, conptyRows),
+		stdout:        os.Stdout,
 		injectDelayMs: injectDelayMs,
 	}
 }
 
-func (p *PTY) Start(outputCallback func(line string)) error {
-	cpty, err := conpty.Start(p.cmd.Path, conpty.ConPtyDimensions(120, 40))
+func (p *PTY) Start(outputCallback func(data []byte)) error {
+	cols, rows := conptyCols, conptyRows
+	if p.headless {
+		cols, rows = p.cols, p.rows
+	}
+	cpty, err := conpty.Start(p.cmd.Path, conpty.ConPtyDimensions(cols, rows))
 	if err != nil {
 		return err
 	}
@@ -39,7 +60,6 @@ func (p *PTY) Start(outputCallback func(line string)) error {
 
 	go func() {
 		reader := bufio.NewReader(cpty)
-		lineBuffer := make([]byte, 0, 1024)
 
 		buf := make([]byte, 4096)
 		for {
@@ -48,61 +68,88 @@ func (p *PTY) Start(outputCallback func(line string)) error {
 				return
 			}
 
-			_, _ = os.Stdout.Write(buf[:n])
-
-			for i := 0; i < n; i++ {
-				b := buf[i]
-				if b == '\n' || b == '\r' {
-					if len(lineBuffer) > 0 {
-						outputCallback(string(lineBuffer))
-						lineBuffer = lineBuffer[:0]
-					}
-				} else {
-					lineBuffer = append(lineBuffer, b)
-				}
-			}
+			_, _ = p.stdout.Write(buf[:n])
 
-			if len(lineBuffer) > 0 {
-				outputCallback(string(lineBuffer))
-			}
+			outputCallback(buf[:n])
 		}
 	}()
 
-	go func() {
-		_, _ = io.Copy(cpty, os.Stdin)
-	}()
+	if !p.headless {
+		go func() {
+			_, _ = io.Copy(cpty, p.hotkey.filter(os.Stdin))
+		}()
+	}
 
 	return nil
 }
 
-func (p *PTY) InjectText(text string, sendEnter bool) error {
+// InjectText types text into the child and optionally presses Enter. Echo
+// detection is not available on Windows, so it never reports an echo.
+func (p *PTY) InjectText(text string, sendEnter bool) (bool, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.closed {
-		return io.ErrClosedPipe
+		return false, io.ErrClosedPipe
 	}
 
-	_, err := p.cpty.Write([]byte(text))
+	_, err := p.cpty.Write([]byte(encodeText(text, p.screen.BracketedPaste(), p.newline)))
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if sendEnter {
 		time.Sleep(time.Duration(p.injectDelayMs) * time.Millisecond)
 		_, err = p.cpty.Write([]byte{'\r'})
 	}
-	return err
+	return false, err
+}
+
+// Write sends raw input, such as keystrokes from an attached client, to the
+// child alongside the local terminal's stdin.
+func (p *PTY) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	return p.cpty.Write(data)
+}
+
+func (p *PTY) Resize(cols, rows int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return io.ErrClosedPipe
+	}
+	if err := p.cpty.Resize(cols, rows); err != nil {
+		return err
+	}
+	p.screen.Resize(cols, rows)
+	return nil
+}
+
+func (p *PTY) Screen() *vterm.Terminal {
+	return p.screen
 }
 
 func (p *PTY) Wait() error {
 	if p.cpty == nil {
 		return nil
 	}
-	_, err := p.cpty.Wait(context.Background())
+	code, err := p.cpty.Wait(context.Background())
+	if err == nil {
+		p.exitCode = int(code)
+	}
 	return err
 }
 
+func (p *PTY) ExitCode() int {
+	return p.exitCode
+}
+
 func (p *PTY) Close() error {
 	p.mu.Lock()
 	p.closed = true
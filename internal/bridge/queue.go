@@ -1,122 +1,695 @@
 package bridge
 
 import (
+	"container/heap"
 	"errors"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-const MaxQueueSize = 100
+const (
+	MinPriority          = 0
+	MaxPriority          = 9
+	DefaultQueueCapacity = 100
+)
 
-var ErrQueueFull = errors.New("injection queue is full")
+var (
+	ErrQueueFull       = errors.New("injection queue is full")
+	ErrNotQueued       = errors.New("injection is not queued")
+	ErrInvalidPosition = errors.New("position out of range")
+	ErrInvalidPriority = errors.New("priority out of range")
+	ErrNotDue          = errors.New("injection is scheduled for later")
+	ErrInSequence      = errors.New("injection is part of a sequence")
+)
 
 type Injection struct {
-	ID       string
-	Text     string
-	Priority bool
-	SyncChan chan struct{}
+	ID   string
+	Text string
+	// Keys, if set, are typed instead of Text; see EnqueueKeys.
+	Keys     []string
+	Priority int
+	Source   string
+	Key      string
+	// Batch lets the injection share a submission with the batchable
+	// injections queued right before or after it.
+	Batch bool
+	// Sequence identifies the ordered sequence the injection was queued
+	// with by EnqueueSequence.
+	Sequence     string
+	EnqueuedAt   time.Time
+	NotBefore    time.Time
+	ExpiresAt    time.Time
+	SyncChan     chan struct{}
+	ResponseChan chan Response
+	// Replaced is the ID of the pending injection with the same Key that
+	// this one superseded.
+	Replaced string
+	// Preempted reports that the tool's running turn was interrupted to
+	// make way for the injection.
+	Preempted bool
+	// Result is set before SyncChan is closed and tells sync waiters
+	// whether the text was submitted or why it never was.
+	Result InjectionState
+
+	seq     uint64
+	index   int
+	waiting bool
+	chain   *chain
 }
 
-type Queue struct {
-	mu    sync.Mutex
+// chain holds the not yet dequeued injections of a sequence. Only the first
+// one sits in a heap; the others follow it out of the queue without anything
+// in between.
+type chain struct {
 	items []*Injection
 }
 
+// QueueUpdate describes an edit to a pending injection. Nil fields are left
+// unchanged. Position moves the item to an explicit slot and gives it the
+// priority of its new neighbours, overriding Priority.
+type QueueUpdate struct {
+	Text     *string
+	Priority *int
+	Position *int
+}
+
+// Queue orders pending injections by priority, highest first, and by
+// enqueue order within a priority level. Injections with a NotBefore time in
+// the future wait in a separate heap until they are due. At most one pending
+// injection holds a given Key.
+type Queue struct {
+	mu      sync.Mutex
+	items   injectionHeap
+	waiting injectionHeap
+	// current is the sequence being dequeued; its remaining items come
+	// next regardless of priority.
+	current  *chain
+	byID     map[string]*Injection
+	byKey    map[string]*Injection
+	capacity int
+	seq      uint64
+	journal  *Journal
+}
+
+// SetJournal makes the queue record moves in j. Everything else about an
+// injection is journaled by History.
+func (q *Queue) SetJournal(j *Journal) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.journal = j
+}
+
 func NewQueue() *Queue {
+	return NewQueueWithCapacity(DefaultQueueCapacity)
+}
+
+func NewQueueWithCapacity(capacity int) *Queue {
+	if capacity <= 0 {
+		capacity = DefaultQueueCapacity
+	}
 	return &Queue{
-		items: make([]*Injection, 0),
+		items:    injectionHeap{before: byPriority},
+		waiting:  injectionHeap{before: byDueTime},
+		byID:     make(map[string]*Injection),
+		byKey:    make(map[string]*Injection),
+		capacity: capacity,
 	}
 }
 
+// Enqueue adds text at the normal level, or at MaxPriority if priority is set.
 func (q *Queue) Enqueue(text string, priority bool, sync bool) (string, error) {
+	inj, err := q.EnqueueWithChan(text, priority, sync)
+	if err != nil {
+		return "", err
+	}
+	return inj.ID, nil
+}
+
+func (q *Queue) EnqueueWithChan(text string, priority bool, sync bool) (*Injection, error) {
+	inj := &Injection{Text: text}
+	if priority {
+		inj.Priority = MaxPriority
+	}
+	if sync {
+		inj.SyncChan = make(chan struct{})
+	}
+
+	if _, err := q.EnqueueInjection(inj); err != nil {
+		return nil, err
+	}
+	return inj, nil
+}
+
+// EnqueueInjection adds a prepared injection, assigning its ID and enqueue
+// time if unset. If a pending injection already holds inj.Key, inj takes
+// over its priority and place in the queue and the older injection is
+// removed and returned.
+func (q *Queue) EnqueueInjection(inj *Injection) (*Injection, error) {
+	if inj.Priority < MinPriority || inj.Priority > MaxPriority {
+		return nil, ErrInvalidPriority
+	}
+
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if len(q.items) >= MaxQueueSize {
-		return "", ErrQueueFull
+	replaced := q.byKey[inj.Key]
+	if replaced == nil && len(q.byID) >= q.capacity {
+		return nil, ErrQueueFull
 	}
 
-	inj := &Injection{
-		ID:       uuid.New().String(),
-		Text:     text,
-		Priority: priority,
+	if inj.ID == "" {
+		inj.ID = uuid.New().String()
+	}
+	if inj.EnqueuedAt.IsZero() {
+		inj.EnqueuedAt = time.Now()
 	}
 
-	if sync {
-		inj.SyncChan = make(chan struct{})
+	if replaced != nil {
+		q.unlink(replaced)
+		inj.Priority = replaced.Priority
+		inj.seq = replaced.seq
+	} else {
+		inj.seq = q.seq
+		q.seq++
 	}
+	q.push(inj)
+	q.byID[inj.ID] = inj
+	if inj.Key != "" {
+		q.byKey[inj.Key] = inj
+	}
+	return replaced, nil
+}
 
-	if priority {
-		q.items = append([]*Injection{inj}, q.items...)
+// EnqueueSequence adds injections that leave the queue back to back, in
+// order, with the priority and NotBefore time of the first. Either all of
+// them are queued or, if they do not fit, none.
+func (q *Queue) EnqueueSequence(injs []*Injection) error {
+	if len(injs) == 0 {
+		return nil
+	}
+	first := injs[0]
+	if first.Priority < MinPriority || first.Priority > MaxPriority {
+		return ErrInvalidPriority
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.byID)+len(injs) > q.capacity {
+		return ErrQueueFull
+	}
+
+	now := time.Now()
+	c := &chain{items: injs}
+	for _, inj := range injs {
+		if inj.ID == "" {
+			inj.ID = uuid.New().String()
+		}
+		if inj.EnqueuedAt.IsZero() {
+			inj.EnqueuedAt = now
+		}
+		inj.Priority = first.Priority
+		inj.NotBefore = first.NotBefore
+		inj.Key = ""
+		inj.Batch = false
+		inj.chain = c
+		inj.seq = q.seq
+		q.seq++
+		q.byID[inj.ID] = inj
+	}
+	q.push(first)
+	return nil
+}
+
+// push adds inj to the heap matching its NotBefore time. The caller must
+// hold q.mu.
+func (q *Queue) push(inj *Injection) {
+	inj.waiting = inj.NotBefore.After(time.Now())
+	if inj.waiting {
+		heap.Push(&q.waiting, inj)
 	} else {
-		q.items = append(q.items, inj)
+		heap.Push(&q.items, inj)
 	}
+}
 
-	return inj.ID, nil
+// promote moves injections that became due into the ready heap. The caller
+// must hold q.mu.
+func (q *Queue) promote(now time.Time) {
+	for q.waiting.Len() > 0 && !q.waiting.items[0].NotBefore.After(now) {
+		inj := heap.Pop(&q.waiting).(*Injection)
+		inj.waiting = false
+		heap.Push(&q.items, inj)
+	}
 }
 
-func (q *Queue) EnqueueWithChan(text string, priority bool, sync bool) (*Injection, error) {
+// Expire removes and returns the injections whose ExpiresAt has passed.
+func (q *Queue) Expire(now time.Time) []*Injection {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if len(q.items) >= MaxQueueSize {
-		return nil, ErrQueueFull
+	var expired []*Injection
+	for _, inj := range q.byID {
+		if !inj.ExpiresAt.IsZero() && !inj.ExpiresAt.After(now) {
+			expired = append(expired, inj)
+		}
 	}
+	for _, inj := range expired {
+		q.unlink(inj)
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].seq < expired[j].seq
+	})
+	return expired
+}
+
+// NextExpiry returns the earliest ExpiresAt of the pending injections.
+func (q *Queue) NextExpiry() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	inj := &Injection{
-		ID:       uuid.New().String(),
-		Text:     text,
-		Priority: priority,
+	var next time.Time
+	for _, inj := range q.byID {
+		if !inj.ExpiresAt.IsZero() && (next.IsZero() || inj.ExpiresAt.Before(next)) {
+			next = inj.ExpiresAt
+		}
 	}
+	return next, !next.IsZero()
+}
 
-	if sync {
-		inj.SyncChan = make(chan struct{})
+// NextDue returns the earliest time a waiting injection becomes due.
+func (q *Queue) NextDue() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.waiting.Len() == 0 {
+		return time.Time{}, false
 	}
+	return q.waiting.items[0].NotBefore, true
+}
 
-	if priority {
-		q.items = append([]*Injection{inj}, q.items...)
-	} else {
-		q.items = append(q.items, inj)
+// Dequeue removes the next due injection. It returns nil if nothing is due.
+func (q *Queue) Dequeue() *Injection {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.next()
+}
+
+// next removes the injection that is due to be typed next: the rest of the
+// current sequence, otherwise the top of the ready heap. The caller must
+// hold q.mu.
+func (q *Queue) next() *Injection {
+	if q.current != nil {
+		inj := q.current.items[0]
+		q.unlink(inj)
+		return inj
 	}
 
-	return inj, nil
+	q.promote(time.Now())
+	if q.items.Len() == 0 {
+		return nil
+	}
+	inj := heap.Pop(&q.items).(*Injection)
+	if c := inj.chain; c != nil {
+		c.items = c.items[1:]
+		if len(c.items) > 0 {
+			q.current = c
+		}
+	}
+	q.forget(inj)
+	return inj
 }
 
-func (q *Queue) Dequeue() *Injection {
+// DequeueBatch removes the next due injection and, if it allows batching,
+// the batchable injections that directly follow it in queue order.
+func (q *Queue) DequeueBatch() []*Injection {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if len(q.items) == 0 {
+	first := q.next()
+	if first == nil {
 		return nil
 	}
+	batch := []*Injection{first}
+	for first.Batch && q.items.Len() > 0 && q.items.items[0].Batch {
+		batch = append(batch, q.next())
+	}
+	return batch
+}
 
-	inj := q.items[0]
-	q.items = q.items[1:]
+// Remove takes a pending injection out of the queue. It returns nil if id
+// is not queued.
+func (q *Queue) Remove(id string) *Injection {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	inj, ok := q.byID[id]
+	if !ok {
+		return nil
+	}
+	q.unlink(inj)
 	return inj
 }
 
+// unlink removes inj from the queue. When the first injection of a sequence
+// leaves a heap, the next one takes its place there. The caller must hold
+// q.mu.
+func (q *Queue) unlink(inj *Injection) {
+	c := inj.chain
+	inHeap := q.inHeap(inj)
+	if inHeap {
+		if inj.waiting {
+			heap.Remove(&q.waiting, inj.index)
+		} else {
+			heap.Remove(&q.items, inj.index)
+		}
+	}
+	if c != nil {
+		c.items = slices.DeleteFunc(c.items, func(other *Injection) bool { return other == inj })
+		switch {
+		case len(c.items) == 0 && c == q.current:
+			q.current = nil
+		case len(c.items) > 0 && inHeap:
+			q.push(c.items[0])
+		}
+	}
+	q.forget(inj)
+}
+
+// forget drops inj from the lookup maps. The caller must hold q.mu.
+func (q *Queue) forget(inj *Injection) {
+	delete(q.byID, inj.ID)
+	if inj.Key != "" && q.byKey[inj.Key] == inj {
+		delete(q.byKey, inj.Key)
+	}
+}
+
+// Update edits a pending injection and returns a copy of it along with its
+// new position.
+func (q *Queue) Update(id string, u QueueUpdate) (Injection, int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.promote(time.Now())
+	inj, ok := q.byID[id]
+	if !ok {
+		return Injection{}, 0, ErrNotQueued
+	}
+	if inj.chain != nil && (u.Position != nil || u.Priority != nil) {
+		return Injection{}, 0, ErrInSequence
+	}
+	if u.Position != nil && inj.waiting {
+		return Injection{}, 0, ErrNotDue
+	}
+	var heapPos int
+	if u.Position != nil {
+		var err error
+		if heapPos, err = q.heapPosition(inj, *u.Position); err != nil {
+			return Injection{}, 0, err
+		}
+	}
+	if u.Priority != nil && (*u.Priority < MinPriority || *u.Priority > MaxPriority) {
+		return Injection{}, 0, ErrInvalidPriority
+	}
+
+	if u.Text != nil {
+		inj.Text = *u.Text
+	}
+	if u.Priority != nil {
+		inj.Priority = *u.Priority
+		if inj.waiting {
+			heap.Fix(&q.waiting, inj.index)
+		} else {
+			heap.Fix(&q.items, inj.index)
+		}
+	}
+	if u.Position != nil {
+		q.move(inj, heapPos)
+	}
+
+	pos := 0
+	for i, other := range q.ordered() {
+		if other == inj {
+			pos = i
+			break
+		}
+	}
+	return *inj, pos, nil
+}
+
+// MoveToFront makes a due injection the next one to leave the queue by
+// raising it to MaxPriority, ahead of everything else at that level. It fails
+// with ErrInSequence while a sequence is being dequeued, since nothing may be
+// typed in between, or if inj follows the first injection of its own
+// sequence.
+func (q *Queue) MoveToFront(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.promote(time.Now())
+	inj, ok := q.byID[id]
+	if !ok {
+		return ErrNotQueued
+	}
+	if q.current != nil || !q.inHeap(inj) {
+		return ErrInSequence
+	}
+	if inj.waiting {
+		return ErrNotDue
+	}
+
+	q.move(inj, 0)
+	inj.Priority = MaxPriority
+	if inj.chain != nil {
+		for _, item := range inj.chain.items {
+			item.Priority = MaxPriority
+		}
+	}
+	heap.Fix(&q.items, inj.index)
+	return nil
+}
+
+// heapPosition translates pos, a place in dequeue order, into a place among
+// the other injections of the ready heap. Positions past the due injections
+// are out of range, and ones that would split a sequence are rejected. The
+// caller must hold q.mu.
+func (q *Queue) heapPosition(inj *Injection, pos int) (int, error) {
+	var others []*Injection
+	for _, other := range q.ordered() {
+		if other != inj {
+			others = append(others, other)
+		}
+	}
+
+	due := 0
+	for _, other := range others {
+		head := other
+		if other.chain != nil {
+			head = other.chain.items[0]
+		}
+		if head.waiting && other.chain != q.current {
+			break
+		}
+		due++
+	}
+	if pos < 0 || pos > due {
+		return 0, ErrInvalidPosition
+	}
+
+	if pos < due && !q.inHeap(others[pos]) {
+		return 0, ErrInSequence
+	}
+	heapPos := 0
+	for _, other := range others[:pos] {
+		if q.inHeap(other) {
+			heapPos++
+		}
+	}
+	return heapPos, nil
+}
+
+// inHeap reports whether inj sits in a heap rather than following the first
+// injection of its sequence. The caller must hold q.mu.
+func (q *Queue) inHeap(inj *Injection) bool {
+	c := inj.chain
+	return c == nil || (c != q.current && c.items[0] == inj)
+}
+
+// move places a due inj at pos by adopting the priority of the item it lands
+// in front of (or behind, at the end) and renumbering enqueue order.
+func (q *Queue) move(inj *Injection, pos int) {
+	order := q.items.sorted()
+	others := make([]*Injection, 0, len(order)-1)
+	for _, other := range order {
+		if other != inj {
+			others = append(others, other)
+		}
+	}
+	if len(others) == 0 {
+		return
+	}
+
+	if pos < len(others) {
+		inj.Priority = others[pos].Priority
+		q.journal.move(inj.ID, others[pos].ID, "")
+	} else {
+		inj.Priority = others[len(others)-1].Priority
+		q.journal.move(inj.ID, "", others[len(others)-1].ID)
+	}
+
+	order = append(others[:pos:pos], inj)
+	order = append(order, others[pos:]...)
+	for i, item := range order {
+		item.seq = uint64(i)
+	}
+	for _, item := range q.waiting.items {
+		item.seq += uint64(len(order))
+	}
+	q.seq += uint64(len(order))
+	heap.Init(&q.items)
+}
+
+// ordered returns the pending injections in dequeue order: the rest of the
+// current sequence, due ones by priority, then waiting ones by due time. The
+// caller must hold q.mu.
+func (q *Queue) ordered() []*Injection {
+	q.promote(time.Now())
+
+	var result []*Injection
+	if q.current != nil {
+		result = append(result, q.current.items...)
+	}
+	for _, inj := range append(q.items.sorted(), q.waiting.sorted()...) {
+		if inj.chain != nil {
+			result = append(result, inj.chain.items...)
+		} else {
+			result = append(result, inj)
+		}
+	}
+	return result
+}
+
 func (q *Queue) Clear() int {
+	return len(q.Drain())
+}
+
+// Drain removes and returns all pending injections in dequeue order.
+func (q *Queue) Drain() []*Injection {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	count := len(q.items)
-	q.items = make([]*Injection, 0)
-	return count
+	items := q.ordered()
+	q.items.items = nil
+	q.waiting.items = nil
+	q.current = nil
+	q.byID = make(map[string]*Injection)
+	q.byKey = make(map[string]*Injection)
+	return items
+}
+
+// Position returns the zero-based place of a pending injection in dequeue
+// order.
+func (q *Queue) Position(id string) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, inj := range q.ordered() {
+		if inj.ID == id {
+			return i, true
+		}
+	}
+	return 0, false
 }
 
 func (q *Queue) Len() int {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	return len(q.items)
+	return len(q.byID)
+}
+
+func (q *Queue) Capacity() int {
+	return q.capacity
 }
 
+// Items returns the pending injections in dequeue order.
 func (q *Queue) Items() []*Injection {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	result := make([]*Injection, len(q.items))
-	copy(result, q.items)
+	return q.ordered()
+}
+
+// Snapshot returns copies of the pending injections in queue order, safe to
+// read while the queue keeps changing.
+func (q *Queue) Snapshot() []Injection {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	order := q.ordered()
+	result := make([]Injection, len(order))
+	for i, inj := range order {
+		result[i] = *inj
+	}
 	return result
 }
+
+// injectionHeap implements heap.Interface with the injection that sorts
+// first according to before at the root.
+type injectionHeap struct {
+	items  []*Injection
+	before func(a, b *Injection) bool
+}
+
+func byPriority(a, b *Injection) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.seq < b.seq
+}
+
+func byDueTime(a, b *Injection) bool {
+	if !a.NotBefore.Equal(b.NotBefore) {
+		return a.NotBefore.Before(b.NotBefore)
+	}
+	return byPriority(a, b)
+}
+
+// sorted returns the heap's injections in order without modifying it.
+func (h *injectionHeap) sorted() []*Injection {
+	result := make([]*Injection, len(h.items))
+	copy(result, h.items)
+	sort.Slice(result, func(i, j int) bool {
+		return h.before(result[i], result[j])
+	})
+	return result
+}
+
+func (h *injectionHeap) Len() int           { return len(h.items) }
+func (h *injectionHeap) Less(i, j int) bool { return h.before(h.items[i], h.items[j]) }
+
+func (h *injectionHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *injectionHeap) Push(x any) {
+	inj := x.(*Injection)
+	inj.index = len(h.items)
+	h.items = append(h.items, inj)
+}
+
+func (h *injectionHeap) Pop() any {
+	old := h.items
+	n := len(old)
+	inj := old[n-1]
+	old[n-1] = nil
+	h.items = old[:n-1]
+	return inj
+}
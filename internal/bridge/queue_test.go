@@ -1,6 +1,11 @@
 package bridge
 
-import "testing"
+import (
+	"container/heap"
+	"fmt"
+	"testing"
+	"time"
+)
 
 func TestQueueEnqueueDequeue(t *testing.T) {
 	q := NewQueue()
@@ -74,7 +79,7 @@ func TestQueueFIFO(t *testing.T) {
 func TestQueueMaxSize(t *testing.T) {
 	q := NewQueue()
 
-	for i := 0; i < MaxQueueSize; i++ {
+	for i := 0; i < DefaultQueueCapacity; i++ {
 		_, err := q.Enqueue("item", false, false)
 		if err != nil {
 			t.Fatalf("Enqueue %d failed: %v", i, err)
@@ -158,3 +163,371 @@ func TestQueueItems(t *testing.T) {
 		t.Error("Items() should return a copy")
 	}
 }
+
+func TestQueueRemove(t *testing.T) {
+	q := NewQueue()
+
+	id1, _ := q.Enqueue("item1", false, false)
+	id2, _ := q.Enqueue("item2", false, false)
+
+	inj := q.Remove(id1)
+	if inj == nil || inj.ID != id1 {
+		t.Fatalf("Remove() = %v, want %s", inj, id1)
+	}
+	if q.Remove(id1) != nil {
+		t.Error("Remove() of a removed item should return nil")
+	}
+	if next := q.Dequeue(); next == nil || next.ID != id2 {
+		t.Errorf("Dequeue() = %v, want %s", next, id2)
+	}
+}
+
+func TestQueueUpdate(t *testing.T) {
+	q := NewQueue()
+
+	id1, _ := q.Enqueue("item1", false, false)
+	id2, _ := q.Enqueue("item2", false, false)
+	id3, _ := q.Enqueue("item3", false, false)
+
+	text := "edited"
+	_, pos, err := q.Update(id2, QueueUpdate{Text: &text})
+	if err != nil || pos != 1 {
+		t.Errorf("Update(text) = %d, %v, want 1, nil", pos, err)
+	}
+
+	priority := 5
+	if _, pos, _ := q.Update(id3, QueueUpdate{Priority: &priority}); pos != 0 {
+		t.Errorf("Update(priority) position = %d, want 0", pos)
+	}
+
+	position := 2
+	inj, pos, _ := q.Update(id3, QueueUpdate{Position: &position})
+	if pos != 2 || inj.Priority != 0 {
+		t.Errorf("Update(position) = %d with priority %d, want 2 with priority 0", pos, inj.Priority)
+	}
+
+	items := q.Items()
+	got := []string{items[0].ID, items[1].ID, items[2].ID}
+	want := []string{id1, id2, id3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+	if items[1].Text != "edited" {
+		t.Errorf("items[1].Text = %q, want %q", items[1].Text, "edited")
+	}
+
+	position = 3
+	if _, _, err := q.Update(id1, QueueUpdate{Position: &position}); err != ErrInvalidPosition {
+		t.Errorf("Update(out of range) error = %v, want ErrInvalidPosition", err)
+	}
+	priority = MaxPriority + 1
+	if _, _, err := q.Update(id1, QueueUpdate{Priority: &priority}); err != ErrInvalidPriority {
+		t.Errorf("Update(bad priority) error = %v, want ErrInvalidPriority", err)
+	}
+	if _, _, err := q.Update("missing", QueueUpdate{Text: &text}); err != ErrNotQueued {
+		t.Errorf("Update(missing) error = %v, want ErrNotQueued", err)
+	}
+}
+
+func TestQueuePriorityLevels(t *testing.T) {
+	q := NewQueue()
+
+	for _, it := range []struct {
+		text     string
+		priority int
+	}{
+		{"low1", 0}, {"high1", 7}, {"mid", 3}, {"high2", 7}, {"low2", 0}, {"top", MaxPriority},
+	} {
+		if _, err := q.EnqueueInjection(&Injection{Text: it.text, Priority: it.priority}); err != nil {
+			t.Fatalf("EnqueueInjection failed: %v", err)
+		}
+	}
+
+	order := []string{"top", "high1", "high2", "mid", "low1", "low2"}
+	for _, expected := range order {
+		if inj := q.Dequeue(); inj.Text != expected {
+			t.Errorf("Got %q, want %q", inj.Text, expected)
+		}
+	}
+
+	if _, err := q.EnqueueInjection(&Injection{Text: "bad", Priority: MaxPriority + 1}); err != ErrInvalidPriority {
+		t.Errorf("Expected ErrInvalidPriority, got %v", err)
+	}
+}
+
+func TestQueueCapacity(t *testing.T) {
+	q := NewQueueWithCapacity(2)
+
+	if _, err := q.Enqueue("item1", false, false); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if _, err := q.Enqueue("item2", false, false); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if _, err := q.Enqueue("overflow", false, false); err != ErrQueueFull {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	if q.Capacity() != 2 {
+		t.Errorf("Capacity() = %d, want 2", q.Capacity())
+	}
+}
+
+func TestQueueSnapshot(t *testing.T) {
+	q := NewQueue()
+
+	id, _ := q.Enqueue("item1", false, false)
+	snap := q.Snapshot()
+	if len(snap) != 1 || snap[0].ID != id || snap[0].EnqueuedAt.IsZero() {
+		t.Fatalf("Snapshot() = %+v, want one item with enqueue time", snap)
+	}
+
+	snap[0].Text = "changed"
+	if q.Items()[0].Text != "item1" {
+		t.Error("Snapshot() should return copies")
+	}
+}
+
+func TestQueueNotBefore(t *testing.T) {
+	q := NewQueue()
+
+	due := time.Now().Add(50 * time.Millisecond)
+	if _, err := q.EnqueueInjection(&Injection{Text: "later", Priority: MaxPriority, NotBefore: due}); err != nil {
+		t.Fatalf("EnqueueInjection failed: %v", err)
+	}
+	if _, err := q.Enqueue("now", false, false); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	if q.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", q.Len())
+	}
+	if items := q.Items(); items[0].Text != "now" || items[1].Text != "later" {
+		t.Errorf("Items() = [%s %s], want due items first", items[0].Text, items[1].Text)
+	}
+	if next, ok := q.NextDue(); !ok || !next.Equal(due) {
+		t.Errorf("NextDue() = %v, %v, want %v", next, ok, due)
+	}
+
+	if inj := q.Dequeue(); inj == nil || inj.Text != "now" {
+		t.Fatalf("Dequeue() = %v, want the due item", inj)
+	}
+	if inj := q.Dequeue(); inj != nil {
+		t.Fatalf("Dequeue() = %q, want nil before the item is due", inj.Text)
+	}
+
+	time.Sleep(time.Until(due))
+	if inj := q.Dequeue(); inj == nil || inj.Text != "later" {
+		t.Errorf("Dequeue() = %v, want the scheduled item once due", inj)
+	}
+	if _, ok := q.NextDue(); ok {
+		t.Error("NextDue() should report nothing once the queue is empty")
+	}
+}
+
+func TestQueueUpdateWaitingPriority(t *testing.T) {
+	q := NewQueue()
+
+	due := time.Now().Add(time.Hour)
+	var ids []string
+	for _, priority := range []int{5, 0, 0} {
+		inj := &Injection{Text: "later", Priority: priority, NotBefore: due}
+		if _, err := q.EnqueueInjection(inj); err != nil {
+			t.Fatalf("EnqueueInjection failed: %v", err)
+		}
+		ids = append(ids, inj.ID)
+	}
+
+	priority := MaxPriority
+	if _, _, err := q.Update(ids[2], QueueUpdate{Priority: &priority}); err != nil {
+		t.Fatalf("Update(priority) failed: %v", err)
+	}
+	if inj := heap.Pop(&q.waiting).(*Injection); inj.ID != ids[2] {
+		t.Errorf("first due item = %s, want the raised one %s", inj.ID, ids[2])
+	}
+}
+
+func TestQueueExpire(t *testing.T) {
+	q := NewQueue()
+
+	now := time.Now()
+	q.EnqueueInjection(&Injection{ID: "stale", Text: "stale", ExpiresAt: now.Add(-time.Second)})
+	q.EnqueueInjection(&Injection{ID: "later", Text: "later", NotBefore: now.Add(time.Hour), ExpiresAt: now.Add(-time.Second)})
+	q.EnqueueInjection(&Injection{ID: "fresh", Text: "fresh", ExpiresAt: now.Add(time.Hour)})
+	q.EnqueueInjection(&Injection{ID: "forever", Text: "forever"})
+
+	if next, ok := q.NextExpiry(); !ok || !next.Equal(now.Add(-time.Second)) {
+		t.Errorf("NextExpiry() = %v, %v, want the stale items' expiry", next, ok)
+	}
+
+	expired := q.Expire(now)
+	if len(expired) != 2 || expired[0].ID != "stale" || expired[1].ID != "later" {
+		t.Fatalf("Expire() = %v, want stale and later", expired)
+	}
+	if q.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", q.Len())
+	}
+	if next, ok := q.NextExpiry(); !ok || !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("NextExpiry() = %v, %v, want the fresh item's expiry", next, ok)
+	}
+}
+
+func TestQueueKey(t *testing.T) {
+	q := NewQueueWithCapacity(3)
+
+	q.EnqueueInjection(&Injection{ID: "screen-1", Text: "screen 1", Key: "screen", Priority: 3})
+	q.EnqueueInjection(&Injection{ID: "other", Text: "other", Priority: 3})
+	q.EnqueueInjection(&Injection{ID: "plain", Text: "plain"})
+
+	replaced, err := q.EnqueueInjection(&Injection{ID: "screen-2", Text: "screen 2", Key: "screen"})
+	if err != nil {
+		t.Fatalf("EnqueueInjection failed: %v", err)
+	}
+	if replaced == nil || replaced.ID != "screen-1" {
+		t.Fatalf("replaced = %v, want screen-1", replaced)
+	}
+
+	items := q.Snapshot()
+	if len(items) != 3 {
+		t.Fatalf("Len() = %d, want 3", len(items))
+	}
+	if items[0].ID != "screen-2" || items[0].Text != "screen 2" || items[0].Priority != 3 {
+		t.Errorf("items[0] = %+v, want screen-2 in screen-1's place", items[0])
+	}
+	if pos, ok := q.Position("plain"); !ok || pos != 2 {
+		t.Errorf("Position(plain) = %d, %v, want 2", pos, ok)
+	}
+
+	if _, err := q.EnqueueInjection(&Injection{Text: "full", Key: "other"}); err != ErrQueueFull {
+		t.Errorf("EnqueueInjection() with a new key = %v, want ErrQueueFull", err)
+	}
+
+	q.Dequeue()
+	replaced, _ = q.EnqueueInjection(&Injection{ID: "screen-3", Text: "screen 3", Key: "screen"})
+	if replaced != nil {
+		t.Errorf("replaced = %v, want nil once the keyed item was dequeued", replaced)
+	}
+}
+
+func TestQueueDequeueBatch(t *testing.T) {
+	q := NewQueue()
+
+	q.EnqueueInjection(&Injection{ID: "a", Text: "a", Batch: true})
+	q.EnqueueInjection(&Injection{ID: "b", Text: "b", Batch: true})
+	q.EnqueueInjection(&Injection{ID: "c", Text: "c"})
+	q.EnqueueInjection(&Injection{ID: "d", Text: "d", Batch: true})
+	q.EnqueueInjection(&Injection{ID: "later", Text: "later", Batch: true, NotBefore: time.Now().Add(time.Hour)})
+
+	var got [][]string
+	for batch := q.DequeueBatch(); batch != nil; batch = q.DequeueBatch() {
+		var ids []string
+		for _, inj := range batch {
+			ids = append(ids, inj.ID)
+		}
+		got = append(got, ids)
+	}
+
+	want := [][]string{{"a", "b"}, {"c"}, {"d"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("batches = %v, want %v", got, want)
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len() = %d, want the scheduled item left", q.Len())
+	}
+}
+
+func TestQueueSequence(t *testing.T) {
+	q := NewQueueWithCapacity(5)
+
+	q.EnqueueInjection(&Injection{ID: "before", Text: "before"})
+	seq := []*Injection{
+		{ID: "s1", Text: "first read X"},
+		{ID: "s2", Text: "now do Y", Priority: MaxPriority},
+		{ID: "s3", Text: "then Z"},
+	}
+	if err := q.EnqueueSequence(seq); err != nil {
+		t.Fatalf("EnqueueSequence failed: %v", err)
+	}
+	if err := q.EnqueueSequence([]*Injection{{Text: "a"}, {Text: "b"}}); err != ErrQueueFull {
+		t.Errorf("EnqueueSequence() over capacity = %v, want ErrQueueFull", err)
+	}
+	if q.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4 after a rejected sequence", q.Len())
+	}
+
+	if _, _, err := q.Update("s2", QueueUpdate{Priority: new(int)}); err != ErrInSequence {
+		t.Errorf("Update(priority) of a sequence item = %v, want ErrInSequence", err)
+	}
+
+	q.Dequeue()
+	if inj := q.Dequeue(); inj.ID != "s1" {
+		t.Fatalf("Dequeue() = %s, want s1", inj.ID)
+	}
+
+	// A higher priority item must not cut into the running sequence.
+	q.EnqueueInjection(&Injection{ID: "urgent", Text: "urgent", Priority: MaxPriority})
+	pos := 0
+	if _, _, err := q.Update("urgent", QueueUpdate{Position: &pos}); err != ErrInSequence {
+		t.Errorf("Update(position) into a sequence = %v, want ErrInSequence", err)
+	}
+	if removed := q.Remove("s2"); removed == nil {
+		t.Fatal("Remove(s2) = nil")
+	}
+
+	var got []string
+	for inj := q.Dequeue(); inj != nil; inj = q.Dequeue() {
+		got = append(got, inj.ID)
+	}
+	if fmt.Sprint(got) != "[s3 urgent]" {
+		t.Errorf("remaining order = %v, want [s3 urgent]", got)
+	}
+}
+
+func TestQueueSequenceRemoveFirst(t *testing.T) {
+	q := NewQueue()
+
+	q.EnqueueSequence([]*Injection{{ID: "s1", Text: "1"}, {ID: "s2", Text: "2"}})
+	q.EnqueueInjection(&Injection{ID: "after", Text: "after"})
+	q.Remove("s1")
+
+	items := q.Snapshot()
+	if len(items) != 2 || items[0].ID != "s2" || items[1].ID != "after" {
+		t.Errorf("Snapshot() = %v, want s2 then after", items)
+	}
+	if inj := q.Dequeue(); inj == nil || inj.ID != "s2" {
+		t.Errorf("Dequeue() = %v, want s2", inj)
+	}
+}
+
+func TestQueueMoveToFront(t *testing.T) {
+	q := NewQueue()
+
+	q.EnqueueInjection(&Injection{ID: "high", Text: "high", Priority: MaxPriority})
+	q.EnqueueInjection(&Injection{ID: "low", Text: "low"})
+	q.EnqueueInjection(&Injection{ID: "later", Text: "later", NotBefore: time.Now().Add(time.Hour)})
+	q.EnqueueSequence([]*Injection{{ID: "s1", Text: "1"}, {ID: "s2", Text: "2"}})
+
+	if err := q.MoveToFront("later"); err != ErrNotDue {
+		t.Errorf("MoveToFront() of a waiting item = %v, want ErrNotDue", err)
+	}
+	if err := q.MoveToFront("s2"); err != ErrInSequence {
+		t.Errorf("MoveToFront() inside a sequence = %v, want ErrInSequence", err)
+	}
+	if err := q.MoveToFront("low"); err != nil {
+		t.Fatalf("MoveToFront failed: %v", err)
+	}
+	if inj := q.Dequeue(); inj.ID != "low" || inj.Priority != MaxPriority {
+		t.Errorf("Dequeue() = %s at %d, want low at MaxPriority", inj.ID, inj.Priority)
+	}
+
+	if err := q.MoveToFront("s1"); err != nil {
+		t.Fatalf("MoveToFront of a sequence failed: %v", err)
+	}
+	if inj := q.Dequeue(); inj.ID != "s1" {
+		t.Fatalf("Dequeue() = %s, want s1", inj.ID)
+	}
+	if err := q.MoveToFront("high"); err != ErrInSequence {
+		t.Errorf("MoveToFront() while a sequence is dequeued = %v, want ErrInSequence", err)
+	}
+}
@@ -0,0 +1,232 @@
+package bridge
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// responseStartTimeout bounds how long a submitted injection whose caller
+// waits for a response is tracked before the tool shows any sign of working
+// on it.
+const responseStartTimeout = 30 * time.Second
+
+var errNoResponse = errors.New("tool did not start responding")
+
+// Response is the agent's output produced by an injection, from the moment
+// the text was typed until the tool went idle again. State tells whether the
+// injection completed, failed or never left the queue.
+type Response struct {
+	Text      string
+	Truncated bool
+	State     InjectionState
+}
+
+// activeInjection tracks the injections currently being typed or answered,
+// more than one when they were batched into a single submission. Only one
+// exists at a time because injections wait for the tool to be idle.
+type activeInjection struct {
+	injs            []*Injection
+	start           int64
+	submitted       bool
+	responseStarted bool
+	timer           *time.Timer
+}
+
+// wantsResponse reports whether a caller waits for the agent's response to
+// any of the injections.
+func (a *activeInjection) wantsResponse() bool {
+	for _, inj := range a.injs {
+		if inj.ResponseChan != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bridge) beginActive(injs ...*Injection) {
+	b.activeMu.Lock()
+	defer b.activeMu.Unlock()
+
+	b.active = &activeInjection{injs: injs, start: b.output.End()}
+}
+
+func (b *Bridge) markSubmitted() {
+	b.activeMu.Lock()
+	defer b.activeMu.Unlock()
+
+	a := b.active
+	if a == nil {
+		return
+	}
+	a.submitted = true
+	if !b.paranoid && a.wantsResponse() {
+		a.timer = time.AfterFunc(b.startTimeout, func() { b.abandonActive(a) })
+	}
+}
+
+// awaitingResponse reports whether a has been submitted but the tool has not
+// started responding yet, while a caller waits for the response. Must be
+// called with b.activeMu held.
+func (b *Bridge) awaitingResponse(a *activeInjection) bool {
+	return !b.paranoid && a.submitted && !a.responseStarted && a.wantsResponse()
+}
+
+// activePending reports whether injections are still being typed or wait
+// for the tool to start responding, so the next one must not be typed yet.
+func (b *Bridge) activePending() bool {
+	b.activeMu.Lock()
+	defer b.activeMu.Unlock()
+	return b.active != nil
+}
+
+func (b *Bridge) markResponseStarted() {
+	b.activeMu.Lock()
+	a := b.active
+	if a == nil || !a.submitted || a.responseStarted {
+		b.activeMu.Unlock()
+		return
+	}
+	a.responseStarted = true
+	b.activeMu.Unlock()
+
+	for _, inj := range a.injs {
+		b.history.Update(inj.ID, StateResponseStarted, nil)
+	}
+}
+
+// finishActive ends tracking of the in-flight injections, recording them as
+// completed once submitted or as failed with err, and delivers the output
+// collected for callers waiting on a response. Without err, injections that
+// have not been submitted yet keep being tracked, and so do injections whose
+// response has not started, since the tool can go idle before it shows that
+// it is working.
+func (b *Bridge) finishActive(err error) {
+	b.activeMu.Lock()
+	a := b.active
+	if a == nil || (err == nil && (!a.submitted || b.awaitingResponse(a))) {
+		b.activeMu.Unlock()
+		return
+	}
+	b.active = nil
+	b.activeMu.Unlock()
+
+	state := StateCompleted
+	if a.submitted {
+		err = nil
+	} else {
+		state = StateFailed
+	}
+	b.complete(a, state, err)
+}
+
+// abandonActive fails a if the tool still has not started responding to it
+// once responseStartTimeout has passed, and moves on to the next injection.
+func (b *Bridge) abandonActive(a *activeInjection) {
+	b.activeMu.Lock()
+	if b.active != a || !b.awaitingResponse(a) {
+		b.activeMu.Unlock()
+		return
+	}
+	b.active = nil
+	b.activeMu.Unlock()
+
+	if b.verbose {
+		log.Printf("No response after %v", b.startTimeout)
+	}
+	b.complete(a, StateFailed, errNoResponse)
+	b.triggerInject()
+}
+
+func (b *Bridge) complete(a *activeInjection, state InjectionState, err error) {
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+
+	var resp *Response
+	for _, inj := range a.injs {
+		b.history.Update(inj.ID, state, err)
+		if inj.ResponseChan == nil {
+			continue
+		}
+		if resp == nil {
+			chunk := b.output.Read(a.start, 0)
+			resp = &Response{
+				Text:      cleanResponse(string(chunk.Data), b.batchText(a.injs)),
+				Truncated: chunk.Truncated || chunk.Next < chunk.End,
+				State:     state,
+			}
+		}
+		inj.ResponseChan <- *resp
+	}
+}
+
+// cleanResponse turns raw terminal output into plain text: escape sequences
+// are stripped, carriage-return overwrites and backspaces are applied, and
+// the echo of the injected prompt is removed.
+func cleanResponse(raw, prompt string) string {
+	text := StripANSI(raw)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if j := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = applyBackspaces(stripControl(line))
+	}
+	text = strings.Join(lines, "\n")
+
+	text = removeEcho(text, prompt)
+	return strings.TrimSpace(text)
+}
+
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\b' || r >= 0x20 && r != 0x7f {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func applyBackspaces(s string) string {
+	if !strings.ContainsRune(s, '\b') {
+		return s
+	}
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if r == '\b' {
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// removeEcho drops everything up to and including the echoed prompt. Tools
+// that wrap or reformat multi-line input usually still echo its last line
+// verbatim, which is used as a fallback.
+func removeEcho(text, prompt string) string {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return text
+	}
+
+	if i := strings.Index(text, prompt); i >= 0 {
+		return text[i+len(prompt):]
+	}
+
+	lines := strings.Split(prompt, "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if last != "" {
+		if i := strings.Index(text, last); i >= 0 {
+			return text[i+len(last):]
+		}
+	}
+	return text
+}
@@ -0,0 +1,192 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCleanResponse(t *testing.T) {
+	raw := "\x1b[2K\r> explain this\r\n\x1b[1m●\x1b[0m It prints hello.\r\nWorking\rDone!  \r\nab\bc\r\n"
+
+	got := cleanResponse(raw, "explain this")
+	want := "● It prints hello.\nDone!  \nac"
+	if got != want {
+		t.Errorf("cleanResponse() = %q, want %q", got, want)
+	}
+}
+
+func TestCleanResponseMultilinePrompt(t *testing.T) {
+	raw := "> first line\r\n  last line\r\nanswer"
+
+	got := cleanResponse(raw, "first line\nlast line")
+	if got != "answer" {
+		t.Errorf("cleanResponse() = %q, want %q", got, "answer")
+	}
+}
+
+func TestResponseCapture(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	_, _ = b.output.Write([]byte("earlier output\r\n"))
+	inj, err := b.Enqueue("hi", EnqueueOptions{WaitResponse: true})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	b.queue.Dequeue()
+
+	b.beginActive(inj)
+	_, _ = b.output.Write([]byte("> hi\r\n"))
+	b.finishActive(nil)
+	if len(inj.ResponseChan) != 0 {
+		t.Fatal("Response should not be delivered before the injection is submitted")
+	}
+
+	b.markSubmitted()
+	b.markResponseStarted()
+	_, _ = b.output.Write([]byte("hello there\r\n"))
+	b.finishActive(nil)
+
+	resp := <-inj.ResponseChan
+	if resp.Text != "hello there" || resp.Truncated {
+		t.Errorf("Response = %+v, want %q", resp, "hello there")
+	}
+
+	rec, ok := b.Injection(inj.ID)
+	if !ok {
+		t.Fatal("Injection record not found")
+	}
+	want := []InjectionState{StateQueued, StateResponseStarted, StateCompleted}
+	if len(rec.Transitions) != len(want) {
+		t.Fatalf("Transitions = %+v, want states %v", rec.Transitions, want)
+	}
+	for i, st := range want {
+		if rec.Transitions[i].State != st {
+			t.Errorf("Transition %d = %q, want %q", i, rec.Transitions[i].State, st)
+		}
+	}
+}
+
+func TestFinishActiveFailure(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	inj, _ := b.Enqueue("hi", EnqueueOptions{})
+	b.queue.Dequeue()
+	b.beginActive(inj)
+	b.finishActive(errChildExited)
+
+	rec, _ := b.Injection(inj.ID)
+	if rec.State != StateFailed || rec.Error != errChildExited.Error() {
+		t.Errorf("State = %q, Error = %q, want failed with %q", rec.State, rec.Error, errChildExited)
+	}
+}
+
+func TestBatchResponse(t *testing.T) {
+	b := newTestBridge(t, Options{Batch: true, BatchHeader: "Updates:"})
+
+	first, _ := b.Enqueue("first", EnqueueOptions{WaitResponse: true})
+	second, _ := b.Enqueue("second", EnqueueOptions{WaitResponse: true})
+
+	batch := b.queue.DequeueBatch()
+	if len(batch) != 2 {
+		t.Fatalf("DequeueBatch() returned %d items, want 2", len(batch))
+	}
+	if got, want := b.batchText(batch), "Updates:\nfirst\n\nsecond"; got != want {
+		t.Errorf("batchText() = %q, want %q", got, want)
+	}
+
+	b.beginActive(batch...)
+	_, _ = b.output.Write([]byte("> Updates:\r\nfirst\r\n\r\nsecond\r\nboth noted\r\n"))
+	b.markSubmitted()
+	b.markResponseStarted()
+	b.finishActive(nil)
+
+	for _, inj := range []*Injection{first, second} {
+		if resp := <-inj.ResponseChan; resp.Text != "both noted" || resp.State != StateCompleted {
+			t.Errorf("Response = %+v, want %q completed", resp, "both noted")
+		}
+		if rec, _ := b.Injection(inj.ID); rec.State != StateCompleted {
+			t.Errorf("State = %q, want %q", rec.State, StateCompleted)
+		}
+	}
+}
+
+func TestResponseWaitsForStart(t *testing.T) {
+	b := newTestBridge(t, Options{})
+
+	inj, _ := b.Enqueue("hi", EnqueueOptions{WaitResponse: true})
+	next, _ := b.Enqueue("next", EnqueueOptions{})
+	b.queue.Dequeue()
+	b.beginActive(inj)
+	b.markSubmitted()
+
+	// The idle timeout ran out before the agent showed its busy pattern.
+	b.onStateChange(true)
+	if len(inj.ResponseChan) != 0 {
+		t.Fatal("Response should not be delivered before the agent started responding")
+	}
+	b.processQueue()
+	if pos, ok := b.Queue().Position(next.ID); !ok || pos != 0 {
+		t.Error("the next injection should wait while the response has not started")
+	}
+
+	b.markResponseStarted()
+	_, _ = b.output.Write([]byte("late answer\r\n"))
+	b.onStateChange(true)
+
+	resp := <-inj.ResponseChan
+	if resp.Text != "late answer" || resp.State != StateCompleted {
+		t.Errorf("Response = %+v, want %q completed", resp, "late answer")
+	}
+}
+
+func TestResponseWithoutBusyPattern(t *testing.T) {
+	b := newTestBridge(t, Options{BusyPattern: "thinking"})
+
+	inj, _ := b.Enqueue("hi", EnqueueOptions{WaitResponse: true})
+	b.queue.Dequeue()
+	b.beginActive(inj)
+	b.markSubmitted()
+
+	// A short turn: the answer is drawn without the busy pattern ever
+	// showing up.
+	b.onOutput([]byte("short answer\r\n"))
+	b.onStateChange(true)
+
+	select {
+	case resp := <-inj.ResponseChan:
+		if resp.Text != "short answer" || resp.State != StateCompleted {
+			t.Errorf("Response = %+v, want %q completed", resp, "short answer")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Response should be delivered once the screen changed after submit")
+	}
+	if b.activePending() {
+		t.Error("the queue should not stay blocked after the response")
+	}
+}
+
+func TestResponseStartTimeout(t *testing.T) {
+	b := newTestBridge(t, Options{})
+	b.startTimeout = 20 * time.Millisecond
+
+	inj, _ := b.Enqueue("hi", EnqueueOptions{WaitResponse: true})
+	b.queue.Dequeue()
+	b.beginActive(inj)
+	b.markSubmitted()
+	b.finishActive(nil)
+
+	select {
+	case resp := <-inj.ResponseChan:
+		if resp.State != StateFailed {
+			t.Errorf("Response.State = %q, want %q", resp.State, StateFailed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no response after the start timeout")
+	}
+	if rec, _ := b.Injection(inj.ID); rec.Error != errNoResponse.Error() {
+		t.Errorf("Error = %q, want %q", rec.Error, errNoResponse)
+	}
+	if b.activePending() {
+		t.Error("the abandoned injection should no longer be tracked")
+	}
+}
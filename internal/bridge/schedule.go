@@ -0,0 +1,190 @@
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MobAI-App/aibridge/internal/cron"
+	"github.com/google/uuid"
+)
+
+// Schedule is a recurring injection. Every time its cron expression fires,
+// Text is queued like a regular injection.
+type Schedule struct {
+	ID        string
+	Spec      string
+	Text      string
+	Priority  int
+	Source    string
+	CreatedAt time.Time
+	// Next is zero if the expression never fires again.
+	Next      time.Time
+	LastRun   time.Time
+	LastID    string
+	LastError string
+}
+
+type scheduleEntry struct {
+	Schedule
+	cron cron.Schedule
+}
+
+// Scheduler queues injections on recurring cron schedules. A single timer
+// is armed for the earliest upcoming run.
+type Scheduler struct {
+	mu      sync.Mutex
+	entries map[string]*scheduleEntry
+	order   []string
+	timer   *time.Timer
+	closed  bool
+	enqueue func(s Schedule) (string, error)
+}
+
+// NewScheduler creates a scheduler that calls enqueue whenever a schedule
+// fires. enqueue returns the ID of the queued injection.
+func NewScheduler(enqueue func(s Schedule) (string, error)) *Scheduler {
+	return &Scheduler{
+		entries: make(map[string]*scheduleEntry),
+		enqueue: enqueue,
+	}
+}
+
+func (s *Scheduler) Add(spec, text string, priority int, source string) (Schedule, error) {
+	if priority < MinPriority || priority > MaxPriority {
+		return Schedule{}, ErrInvalidPriority
+	}
+	c, err := cron.Parse(spec)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+
+	now := time.Now()
+	e := &scheduleEntry{
+		Schedule: Schedule{
+			ID:        uuid.New().String(),
+			Spec:      spec,
+			Text:      text,
+			Priority:  priority,
+			Source:    source,
+			CreatedAt: now,
+			Next:      c.Next(now),
+		},
+		cron: c,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[e.ID] = e
+	s.order = append(s.order, e.ID)
+	s.arm()
+	return e.Schedule, nil
+}
+
+func (s *Scheduler) Remove(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[id]; !ok {
+		return false
+	}
+	delete(s.entries, id)
+	for i, v := range s.order {
+		if v == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	s.arm()
+	return true
+}
+
+func (s *Scheduler) Get(id string) (Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return Schedule{}, false
+	}
+	return e.Schedule, true
+}
+
+// List returns all schedules in creation order.
+func (s *Scheduler) List() []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Schedule, len(s.order))
+	for i, id := range s.order {
+		result[i] = s.entries[id].Schedule
+	}
+	return result
+}
+
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+}
+
+// arm resets the timer to the earliest upcoming run. The caller must hold
+// s.mu.
+func (s *Scheduler) arm() {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.closed {
+		return
+	}
+
+	var next time.Time
+	for _, e := range s.entries {
+		if !e.Next.IsZero() && (next.IsZero() || e.Next.Before(next)) {
+			next = e.Next
+		}
+	}
+	if next.IsZero() {
+		return
+	}
+	s.timer = time.AfterFunc(time.Until(next), s.run)
+}
+
+// run queues every schedule that is due and re-arms the timer.
+func (s *Scheduler) run() {
+	now := time.Now()
+
+	s.mu.Lock()
+	var due []*scheduleEntry
+	for _, id := range s.order {
+		e := s.entries[id]
+		if !e.Next.IsZero() && !e.Next.After(now) {
+			due = append(due, e)
+			e.LastRun = now
+			e.Next = e.cron.Next(now)
+		}
+	}
+	s.arm()
+	s.mu.Unlock()
+
+	for _, e := range due {
+		s.mu.Lock()
+		sched := e.Schedule
+		s.mu.Unlock()
+
+		id, err := s.enqueue(sched)
+
+		s.mu.Lock()
+		e.LastID = id
+		e.LastError = ""
+		if err != nil {
+			e.LastError = err.Error()
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,73 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRun(t *testing.T) {
+	var fired []Schedule
+	s := NewScheduler(func(sched Schedule) (string, error) {
+		fired = append(fired, sched)
+		if len(fired) > 1 {
+			return "", errors.New("queue is full")
+		}
+		return "inj-1", nil
+	})
+	defer s.Close()
+
+	sched, err := s.Add("*/5 * * * *", "status report", 2, "cron")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if sched.Next.IsZero() || sched.Next.Minute()%5 != 0 {
+		t.Errorf("Next = %v, want a multiple of five minutes", sched.Next)
+	}
+
+	s.mu.Lock()
+	s.entries[sched.ID].Next = time.Now().Add(-time.Second)
+	s.mu.Unlock()
+	s.run()
+
+	if len(fired) != 1 || fired[0].Text != "status report" || fired[0].Priority != 2 {
+		t.Fatalf("fired = %+v, want one run of the schedule", fired)
+	}
+	got, _ := s.Get(sched.ID)
+	if got.LastID != "inj-1" || got.LastRun.IsZero() || !got.Next.After(time.Now()) {
+		t.Errorf("schedule after run = %+v", got)
+	}
+
+	s.mu.Lock()
+	s.entries[sched.ID].Next = time.Now().Add(-time.Second)
+	s.mu.Unlock()
+	s.run()
+	if got, _ := s.Get(sched.ID); got.LastError != "queue is full" {
+		t.Errorf("LastError = %q, want %q", got.LastError, "queue is full")
+	}
+}
+
+func TestSchedulerAddRemove(t *testing.T) {
+	s := NewScheduler(func(Schedule) (string, error) { return "", nil })
+	defer s.Close()
+
+	if _, err := s.Add("not a cron", "text", 0, ""); err == nil {
+		t.Error("Add() with an invalid expression should fail")
+	}
+	if _, err := s.Add("@hourly", "text", MaxPriority+1, ""); err != ErrInvalidPriority {
+		t.Errorf("Add() error = %v, want ErrInvalidPriority", err)
+	}
+
+	a, _ := s.Add("@hourly", "a", 0, "")
+	b, _ := s.Add("@daily", "b", 0, "")
+	if list := s.List(); len(list) != 2 || list[0].ID != a.ID || list[1].ID != b.ID {
+		t.Errorf("List() = %+v, want both schedules in order", list)
+	}
+
+	if !s.Remove(a.ID) || s.Remove(a.ID) {
+		t.Error("Remove() should succeed once")
+	}
+	if _, ok := s.Get(a.ID); ok {
+		t.Error("Get() should not find a removed schedule")
+	}
+}
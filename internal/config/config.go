@@ -1,8 +1,41 @@
 package config
 
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
 const (
 	DefaultPort        = 9999
 	DefaultHost        = "127.0.0.1"
 	DefaultTimeout     = 30
 	DefaultInjectDelay = 50
+	DefaultScrollback  = 1 << 20
+	DefaultQueueSize   = 100
+	DefaultTTL         = 0
+	DefaultIdempotency = 3600
+	DefaultPauseKey    = "ctrl-]"
+	DefaultPreempt     = 30
+	DefaultCols        = 120
+	DefaultRows        = 40
 )
+
+// DefaultStateDir returns the directory for persistent state such as the
+// queue journal: $XDG_STATE_HOME/aibridge, ~/.local/state/aibridge, or
+// %LocalAppData%\aibridge on Windows.
+func DefaultStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "aibridge")
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return filepath.Join(dir, "aibridge")
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "aibridge")
+	}
+	return filepath.Join(home, ".local", "state", "aibridge")
+}
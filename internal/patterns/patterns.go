@@ -2,12 +2,21 @@ package patterns
 
 type Pattern struct {
 	Regex string
+	Ready string
+	// Newline is typed for line breaks in injected text when the tool has
+	// not enabled bracketed paste. Empty types them as-is.
+	Newline string
+	// Interrupt names the key that stops the tool's current turn, as
+	// accepted by POST /keys. Empty means ctrl-c.
+	Interrupt string
 }
 
 var BuiltinPatterns = map[string]Pattern{
-	"claude": {Regex: `thinking`},
-	"codex":  {Regex: `esc to interrupt`},
-	"gemini": {Regex: `esc to cancel`},
+	// Claude Code draws its input box during a turn as well, so only an
+	// empty prompt line inside it means the tool is waiting for input.
+	"claude": {Regex: `thinking`, Ready: `^\s*│?\s*>\s*│?\s*$`, Newline: "\\\r", Interrupt: "esc"},
+	"codex":  {Regex: `esc to interrupt`, Ready: `^\s*[›▌]\s`, Interrupt: "esc"},
+	"gemini": {Regex: `esc to cancel`, Ready: `Type your message`, Interrupt: "esc"},
 }
 
 func GetPattern(toolName string) *Pattern {
@@ -17,8 +26,8 @@ func GetPattern(toolName string) *Pattern {
 	return nil
 }
 
+// DefaultPattern is used for tools without a built-in pattern. It has no
+// busy regex, so any screen change counts as busy and silence as idle.
 func DefaultPattern() *Pattern {
-	return &Pattern{
-		Regex: `esc to interrupt`,
-	}
+	return &Pattern{}
 }
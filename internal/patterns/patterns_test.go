@@ -1,6 +1,9 @@
 package patterns
 
-import "testing"
+import (
+	"regexp"
+	"testing"
+)
 
 func TestGetPattern(t *testing.T) {
 	tests := []struct {
@@ -31,7 +34,59 @@ func TestGetPatternUnknown(t *testing.T) {
 
 func TestDefaultPattern(t *testing.T) {
 	p := DefaultPattern()
-	if p == nil || p.Regex == "" {
-		t.Errorf("DefaultPattern() = %v, want non-nil with non-empty Regex", p)
+	if p == nil || p.Regex != "" || p.Ready != "" {
+		t.Errorf("DefaultPattern() = %v, want no patterns so silence decides", p)
+	}
+}
+
+func TestBuiltinReadyPatterns(t *testing.T) {
+	tests := []struct {
+		tool string
+		line string
+	}{
+		{"claude", "│ >                                    │"},
+		{"claude", "> "},
+		{"codex", "› Ask Codex to do anything"},
+		{"gemini", ">   Type your message or @path/to/file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			p := GetPattern(tt.tool)
+			if p == nil || p.Ready == "" {
+				t.Fatalf("GetPattern(%q) has no ready pattern", tt.tool)
+			}
+			re, err := regexp.Compile(p.Ready)
+			if err != nil {
+				t.Fatalf("Ready pattern for %q does not compile: %v", tt.tool, err)
+			}
+			if !re.MatchString(tt.line) {
+				t.Errorf("Ready pattern %q does not match %q", p.Ready, tt.line)
+			}
+		})
+	}
+}
+
+func TestClaudeReadyIgnoresBorder(t *testing.T) {
+	re := regexp.MustCompile(GetPattern("claude").Ready)
+	for _, line := range []string{
+		"╭──────────────────────────────────────╮",
+		"────────────────────────────────────────",
+		"│ > fix the failing test               │",
+	} {
+		if re.MatchString(line) {
+			t.Errorf("Ready pattern matches %q", line)
+		}
+	}
+}
+
+func TestBuiltinInterruptKeys(t *testing.T) {
+	for _, tool := range []string{"claude", "codex", "gemini"} {
+		if p := GetPattern(tool); p.Interrupt != "esc" {
+			t.Errorf("GetPattern(%q).Interrupt = %q, want esc", tool, p.Interrupt)
+		}
+	}
+	if p := DefaultPattern(); p.Interrupt != "" {
+		t.Errorf("DefaultPattern().Interrupt = %q, want empty for ctrl-c", p.Interrupt)
 	}
 }
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MobAI-App/aibridge/internal/bridge"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	attachPingInterval = 30 * time.Second
+	attachPongTimeout  = 60 * time.Second
+	attachWriteTimeout = 10 * time.Second
+)
+
+// checkOrigin keeps web pages from typing into the session: browsers send
+// an Origin header, and only loopback origins, the server's own address and
+// origins allowed with AllowOrigins may attach. Clients other than browsers
+// send no Origin and are let through.
+func (h *Handlers) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range h.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	// A host name could be rebound to this machine, so only an IP address
+	// counts as the server's own.
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || strings.EqualFold(u.Host, r.Host))
+}
+
+// AttachMessage is a text frame exchanged on /attach. Clients send "input"
+// and "resize" messages; the server sends "exit" when the child exits.
+type AttachMessage struct {
+	Type     string `json:"type"`
+	Data     string `json:"data,omitempty"`
+	Cols     int    `json:"cols,omitempty"`
+	Rows     int    `json:"rows,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+}
+
+// Attach upgrades to a WebSocket that mirrors the wrapped session. The
+// client first receives a redraw of the current screen, then every output
+// chunk as a binary frame. Binary frames from the client are typed into the
+// session unless the connection was opened with readonly=true.
+func (h *Handlers) Attach(w http.ResponseWriter, r *http.Request) {
+	readOnly := r.URL.Query().Get("readonly") == "true"
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin:     h.checkOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	snap, sub := h.bridge.Attach()
+	defer sub.Close()
+
+	readDone := make(chan struct{})
+	go h.attachReadLoop(conn, readOnly, readDone)
+
+	ping := time.NewTicker(attachPingInterval)
+	defer ping.Stop()
+
+	write := func(messageType int, data []byte) bool {
+		_ = conn.SetWriteDeadline(time.Now().Add(attachWriteTimeout))
+		return conn.WriteMessage(messageType, data) == nil
+	}
+	closeWith := func(code int, text string) {
+		_ = conn.SetWriteDeadline(time.Now().Add(attachWriteTimeout))
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+	}
+
+	if !write(websocket.BinaryMessage, snap.Redraw()) {
+		return
+	}
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok {
+				closeWith(websocket.CloseTryAgainLater, "event stream closed")
+				return
+			}
+			switch data := ev.Data.(type) {
+			case bridge.OutputEvent:
+				if !write(websocket.BinaryMessage, []byte(data.Data)) {
+					return
+				}
+			case bridge.ChildExitedEvent:
+				code := data.ExitCode
+				msg, _ := json.Marshal(AttachMessage{Type: "exit", ExitCode: &code})
+				write(websocket.TextMessage, msg)
+				closeWith(websocket.CloseNormalClosure, "child exited")
+				return
+			}
+		case <-ping.C:
+			if !write(websocket.PingMessage, nil) {
+				return
+			}
+		case <-readDone:
+			return
+		case <-h.done:
+			closeWith(websocket.CloseGoingAway, "server shutting down")
+			return
+		}
+	}
+}
+
+func (h *Handlers) attachReadLoop(conn *websocket.Conn, readOnly bool, done chan<- struct{}) {
+	defer close(done)
+
+	_ = conn.SetReadDeadline(time.Now().Add(attachPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(attachPongTimeout))
+	})
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(attachPongTimeout))
+
+		if messageType == websocket.BinaryMessage {
+			if !readOnly {
+				_ = h.bridge.WriteInput(data)
+			}
+			continue
+		}
+
+		var msg AttachMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		if readOnly {
+			continue
+		}
+
+		switch msg.Type {
+		case "input":
+			_ = h.bridge.WriteInput([]byte(msg.Data))
+		case "resize":
+			_ = h.bridge.Resize(msg.Cols, msg.Rows)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MobAI-App/aibridge/internal/bridge"
+	"github.com/gorilla/websocket"
+)
+
+func TestAttachStreamsScreenAndOutput(t *testing.T) {
+	b := newTestBridge(t)
+	_, _ = b.Screen().Write([]byte("existing screen"))
+	h := NewHandlers(b)
+
+	srv := httptest.NewServer(http.HandlerFunc(h.Attach))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?readonly=true", nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if messageType != websocket.BinaryMessage || !strings.Contains(string(data), "existing screen") {
+		t.Errorf("first frame = %q, want a redraw of the current screen", data)
+	}
+
+	b.Events().Publish(bridge.EventOutput, bridge.OutputEvent{Data: "new output"})
+	_, data, err = conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if string(data) != "new output" {
+		t.Errorf("output frame = %q, want %q", data, "new output")
+	}
+
+	b.Events().Publish(bridge.EventChildExited, bridge.ChildExitedEvent{ExitCode: 3})
+	messageType, data, err = conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+
+	var msg AttachMessage
+	if messageType != websocket.TextMessage || json.Unmarshal(data, &msg) != nil {
+		t.Fatalf("exit frame = %q, want a JSON text message", data)
+	}
+	if msg.Type != "exit" || msg.ExitCode == nil || *msg.ExitCode != 3 {
+		t.Errorf("exit message = %+v, want exit code 3", msg)
+	}
+}
+
+func TestAttachOrigin(t *testing.T) {
+	h := NewHandlers(newTestBridge(t))
+	h.allowedOrigins = []string{"https://app.example.com"}
+
+	srv := httptest.NewServer(http.HandlerFunc(h.Attach))
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://localhost:3000", true},
+		{"http://127.0.0.1:5173", true},
+		{srv.URL, true},
+		{"https://app.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://attacker.test:9999", false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.origin != "" {
+			header.Set("Origin", tt.origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if conn != nil {
+			conn.Close()
+		}
+		if tt.ok && err != nil {
+			t.Errorf("Origin %q: Dial failed: %v", tt.origin, err)
+		}
+		if !tt.ok && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+			t.Errorf("Origin %q: want the upgrade rejected with 403, got %v", tt.origin, err)
+		}
+	}
+}
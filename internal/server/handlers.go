@@ -3,7 +3,12 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/MobAI-App/aibridge/internal/bridge"
@@ -11,12 +16,36 @@ import (
 
 const Version = "1.0.0"
 
+const (
+	defaultOutputLimit = 64 << 10
+	eventsKeepAlive    = 15 * time.Second
+	syncTimeout        = 300 * time.Second
+	interruptTimeout   = 10 * time.Second
+	previewLength      = 80
+)
+
+var priorityRangeError = fmt.Sprintf("priority must be between %d and %d", bridge.MinPriority, bridge.MaxPriority)
+
 type Handlers struct {
-	bridge *bridge.Bridge
+	bridge         *bridge.Bridge
+	allowedOrigins []string
+	done           chan struct{}
+	closeOnce      sync.Once
 }
 
 func NewHandlers(b *bridge.Bridge) *Handlers {
-	return &Handlers{bridge: b}
+	return &Handlers{
+		bridge: b,
+		done:   make(chan struct{}),
+	}
+}
+
+// Close ends long-lived responses such as event streams so the HTTP server
+// can shut down without waiting for clients to disconnect.
+func (h *Handlers) Close() {
+	h.closeOnce.Do(func() {
+		close(h.done)
+	})
 }
 
 type HealthResponse struct {
@@ -34,6 +63,7 @@ func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
 type StatusResponse struct {
 	Idle          bool    `json:"idle"`
 	QueueLength   int     `json:"queue_length"`
+	Paused        bool    `json:"paused"`
 	ChildRunning  bool    `json:"child_running"`
 	ChildTool     string  `json:"child_tool"`
 	UptimeSeconds float64 `json:"uptime_seconds"`
@@ -43,21 +73,80 @@ func (h *Handlers) Status(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, StatusResponse{
 		Idle:          h.bridge.IsIdle(),
 		QueueLength:   h.bridge.Queue().Len(),
+		Paused:        h.bridge.IsPaused(),
 		ChildRunning:  h.bridge.IsChildRunning(),
 		ChildTool:     h.bridge.ToolName(),
 		UptimeSeconds: h.bridge.UptimeSeconds(),
 	})
 }
 
+// Priority is a level between bridge.MinPriority and bridge.MaxPriority. It
+// also accepts a boolean, where true means the top level.
+type Priority int
+
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var flag bool
+	if err := json.Unmarshal(data, &flag); err == nil {
+		*p = bridge.MinPriority
+		if flag {
+			*p = bridge.MaxPriority
+		}
+		return nil
+	}
+
+	var level int
+	if err := json.Unmarshal(data, &level); err != nil {
+		return err
+	}
+	*p = Priority(level)
+	return nil
+}
+
+// Duration accepts a number of seconds or a Go duration string like "90s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
 type InjectRequest struct {
-	Text     string `json:"text"`
-	Priority bool   `json:"priority"`
+	Text       string     `json:"text"`
+	Priority   Priority   `json:"priority"`
+	Source     string     `json:"source,omitempty"`
+	Key        string     `json:"key,omitempty"`
+	Batch      bool       `json:"batch,omitempty"`
+	Preempt    bool       `json:"preempt,omitempty"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+	Delay      Duration   `json:"delay,omitempty"`
+	TTLSeconds float64    `json:"ttl_seconds,omitempty"`
 }
 
 type InjectResponse struct {
-	ID       string `json:"id"`
-	Queued   bool   `json:"queued"`
-	Position int    `json:"position"`
+	ID                string     `json:"id"`
+	Queued            bool       `json:"queued"`
+	Position          int        `json:"position"`
+	Replaced          string     `json:"replaced,omitempty"`
+	Duplicate         bool       `json:"duplicate,omitempty"`
+	Preempted         bool       `json:"preempted,omitempty"`
+	NotBefore         *time.Time `json:"not_before,omitempty"`
+	Status            string     `json:"status,omitempty"`
+	Response          string     `json:"response,omitempty"`
+	ResponseTruncated bool       `json:"response_truncated,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -81,27 +170,86 @@ func (h *Handlers) Inject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	syncMode := r.URL.Query().Get("sync") == "true"
+	notBefore, err := timing(req.NotBefore, req.Delay, req.TTLSeconds)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
 
-	inj, err := h.bridge.Queue().EnqueueWithChan(req.Text, req.Priority, syncMode)
-	if err == bridge.ErrQueueFull {
+	query := r.URL.Query()
+	syncMode := query.Get("sync") == "true"
+	waitMode := query.Get("wait")
+	if waitMode != "" && waitMode != "response" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "wait must be response"})
+		return
+	}
+	waitResponse := waitMode == "response"
+
+	inj, err := h.bridge.Enqueue(req.Text, bridge.EnqueueOptions{
+		Priority:       int(req.Priority),
+		Source:         req.Source,
+		Key:            req.Key,
+		Batch:          req.Batch,
+		Preempt:        req.Preempt,
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+		NotBefore:      notBefore,
+		TTL:            time.Duration(req.TTLSeconds * float64(time.Second)),
+		Sync:           syncMode,
+		WaitResponse:   waitResponse,
+	})
+	var dup *bridge.DuplicateError
+	if errors.As(err, &dup) {
+		h.writeDuplicate(w, dup.ID)
+		return
+	}
+	switch err {
+	case nil:
+	case bridge.ErrQueueFull:
 		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "queue is full"})
 		return
+	case bridge.ErrInvalidPriority:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: priorityRangeError})
+		return
+	default:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
 	}
 
-	h.bridge.NotifyEnqueue()
+	if waitResponse {
+		ctx, cancel := context.WithTimeout(r.Context(), syncTimeout)
+		defer cancel()
+
+		select {
+		case resp := <-inj.ResponseChan:
+			writeJSON(w, http.StatusOK, InjectResponse{
+				ID:                inj.ID,
+				Queued:            true,
+				Position:          0,
+				Replaced:          inj.Replaced,
+				Preempted:         inj.Preempted,
+				Status:            string(resp.State),
+				Response:          resp.Text,
+				ResponseTruncated: resp.Truncated,
+			})
+		case <-ctx.Done():
+			writeJSON(w, http.StatusRequestTimeout, ErrorResponse{Error: "response timeout"})
+		}
+		return
+	}
 
 	if syncMode {
-		timeout := 300 * time.Second
-		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		ctx, cancel := context.WithTimeout(r.Context(), syncTimeout)
 		defer cancel()
 
 		select {
 		case <-inj.SyncChan:
 			writeJSON(w, http.StatusOK, InjectResponse{
-				ID:       inj.ID,
-				Queued:   true,
-				Position: 0,
+				ID:        inj.ID,
+				Queued:    true,
+				Position:  0,
+				Replaced:  inj.Replaced,
+				Preempted: inj.Preempted,
+				Status:    string(inj.Result),
 			})
 		case <-ctx.Done():
 			writeJSON(w, http.StatusRequestTimeout, ErrorResponse{Error: "injection timeout"})
@@ -109,22 +257,534 @@ func (h *Handlers) Inject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, InjectResponse{
-		ID:       inj.ID,
-		Queued:   true,
-		Position: h.bridge.Queue().Len(),
+	resp := InjectResponse{
+		ID:        inj.ID,
+		Queued:    true,
+		Replaced:  inj.Replaced,
+		Preempted: inj.Preempted,
+	}
+	if pos, ok := h.bridge.Queue().Position(inj.ID); ok {
+		resp.Position = pos + 1
+	}
+	if !notBefore.IsZero() {
+		resp.NotBefore = &notBefore
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// timing validates the scheduling fields shared by inject requests and
+// resolves them to a NotBefore time.
+func timing(notBefore *time.Time, delay Duration, ttlSeconds float64) (time.Time, error) {
+	switch {
+	case notBefore != nil && delay != 0:
+		return time.Time{}, errors.New("use either not_before or delay")
+	case delay < 0:
+		return time.Time{}, errors.New("delay must not be negative")
+	case ttlSeconds < 0:
+		return time.Time{}, errors.New("ttl_seconds must not be negative")
+	case notBefore != nil:
+		return *notBefore, nil
+	case delay > 0:
+		return time.Now().Add(time.Duration(delay)), nil
+	}
+	return time.Time{}, nil
+}
+
+type InjectBatchItem struct {
+	Text string `json:"text"`
+}
+
+type InjectBatchRequest struct {
+	Items      []InjectBatchItem `json:"items"`
+	Priority   Priority          `json:"priority"`
+	Source     string            `json:"source,omitempty"`
+	Preempt    bool              `json:"preempt,omitempty"`
+	NotBefore  *time.Time        `json:"not_before,omitempty"`
+	Delay      Duration          `json:"delay,omitempty"`
+	TTLSeconds float64           `json:"ttl_seconds,omitempty"`
+}
+
+type InjectBatchResponse struct {
+	IDs       []string   `json:"ids"`
+	Queued    bool       `json:"queued"`
+	Position  int        `json:"position"`
+	Preempted bool       `json:"preempted,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+}
+
+// InjectBatch queues several texts as one sequence that is typed in order,
+// one agent turn each, with nothing injected in between.
+func (h *Handlers) InjectBatch(w http.ResponseWriter, r *http.Request) {
+	if !h.bridge.IsChildRunning() {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "child process not running"})
+		return
+	}
+
+	var req InjectBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if len(req.Items) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "items are required"})
+		return
+	}
+	texts := make([]string, len(req.Items))
+	for i, item := range req.Items {
+		if item.Text == "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("items[%d]: text is required", i)})
+			return
+		}
+		texts[i] = item.Text
+	}
+
+	notBefore, err := timing(req.NotBefore, req.Delay, req.TTLSeconds)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	injs, err := h.bridge.EnqueueSequence(texts, bridge.EnqueueOptions{
+		Priority:  int(req.Priority),
+		Source:    req.Source,
+		Preempt:   req.Preempt,
+		NotBefore: notBefore,
+		TTL:       time.Duration(req.TTLSeconds * float64(time.Second)),
+	})
+	switch err {
+	case nil:
+	case bridge.ErrQueueFull:
+		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "queue is full"})
+		return
+	case bridge.ErrInvalidPriority:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: priorityRangeError})
+		return
+	default:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := InjectBatchResponse{IDs: make([]string, len(injs)), Queued: true, Preempted: injs[0].Preempted}
+	for i, inj := range injs {
+		resp.IDs[i] = inj.ID
+	}
+	if pos, ok := h.bridge.Queue().Position(injs[0].ID); ok {
+		resp.Position = pos + 1
+	}
+	if !notBefore.IsZero() {
+		resp.NotBefore = &notBefore
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeDuplicate answers a retried request with the current status of the
+// injection it originally queued.
+func (h *Handlers) writeDuplicate(w http.ResponseWriter, id string) {
+	resp := InjectResponse{ID: id, Duplicate: true}
+	if rec, ok := h.bridge.Injection(id); ok {
+		resp.Status = string(rec.State)
+	}
+	if pos, ok := h.bridge.Queue().Position(id); ok {
+		resp.Queued = true
+		resp.Position = pos + 1
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type InjectionTransition struct {
+	State string    `json:"state"`
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+type InjectionStatusResponse struct {
+	ID        string                `json:"id"`
+	State     string                `json:"state"`
+	Priority  int                   `json:"priority"`
+	Error     string                `json:"error,omitempty"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+	History   []InjectionTransition `json:"history"`
+}
+
+func (h *Handlers) InjectionStatus(w http.ResponseWriter, r *http.Request) {
+	rec, ok := h.bridge.Injection(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "injection not found"})
+		return
+	}
+
+	history := make([]InjectionTransition, len(rec.Transitions))
+	for i, t := range rec.Transitions {
+		history[i] = InjectionTransition{State: string(t.State), Time: t.Time, Error: t.Error}
+	}
+	writeJSON(w, http.StatusOK, InjectionStatusResponse{
+		ID:        rec.ID,
+		State:     string(rec.State),
+		Priority:  rec.Priority,
+		Error:     rec.Error,
+		CreatedAt: rec.CreatedAt,
+		UpdatedAt: rec.UpdatedAt,
+		History:   history,
 	})
 }
 
+type QueueItem struct {
+	ID         string     `json:"id"`
+	Position   int        `json:"position"`
+	Priority   int        `json:"priority"`
+	Source     string     `json:"source,omitempty"`
+	Key        string     `json:"key,omitempty"`
+	Keys       []string   `json:"keys,omitempty"`
+	Batch      bool       `json:"batch,omitempty"`
+	Sequence   string     `json:"sequence,omitempty"`
+	EnqueuedAt time.Time  `json:"enqueued_at"`
+	NotBefore  *time.Time `json:"not_before,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Preview    string     `json:"preview"`
+	Text       string     `json:"text,omitempty"`
+}
+
+type QueueListResponse struct {
+	Items []QueueItem `json:"items"`
+}
+
+func (h *Handlers) QueueList(w http.ResponseWriter, r *http.Request) {
+	full := r.URL.Query().Get("full") == "true"
+
+	injections := h.bridge.Queue().Snapshot()
+	items := make([]QueueItem, len(injections))
+	for i, inj := range injections {
+		items[i] = QueueItem{
+			ID:         inj.ID,
+			Position:   i + 1,
+			Priority:   inj.Priority,
+			Source:     inj.Source,
+			Key:        inj.Key,
+			Keys:       inj.Keys,
+			Batch:      inj.Batch,
+			Sequence:   inj.Sequence,
+			EnqueuedAt: inj.EnqueuedAt,
+			Preview:    preview(inj.Text),
+		}
+		if !inj.NotBefore.IsZero() {
+			items[i].NotBefore = &inj.NotBefore
+		}
+		if !inj.ExpiresAt.IsZero() {
+			items[i].ExpiresAt = &inj.ExpiresAt
+		}
+		if full {
+			items[i].Text = inj.Text
+		}
+	}
+	writeJSON(w, http.StatusOK, QueueListResponse{Items: items})
+}
+
+// preview shortens text to its first line, limited to previewLength runes.
+func preview(text string) string {
+	line, _, more := strings.Cut(text, "\n")
+	runes := []rune(line)
+	if len(runes) > previewLength {
+		runes, more = runes[:previewLength], true
+	}
+	if more {
+		return string(runes) + "…"
+	}
+	return string(runes)
+}
+
 type QueueClearResponse struct {
 	Cleared int `json:"cleared"`
 }
 
 func (h *Handlers) QueueClear(w http.ResponseWriter, r *http.Request) {
-	count := h.bridge.Queue().Clear()
+	count := h.bridge.ClearQueue()
 	writeJSON(w, http.StatusOK, QueueClearResponse{Cleared: count})
 }
 
+type QueuePauseResponse struct {
+	Paused bool `json:"paused"`
+}
+
+func (h *Handlers) QueuePause(w http.ResponseWriter, r *http.Request) {
+	h.bridge.Pause()
+	writeJSON(w, http.StatusOK, QueuePauseResponse{Paused: true})
+}
+
+func (h *Handlers) QueueResume(w http.ResponseWriter, r *http.Request) {
+	h.bridge.Resume()
+	writeJSON(w, http.StatusOK, QueuePauseResponse{Paused: false})
+}
+
+type QueueCancelResponse struct {
+	ID        string `json:"id"`
+	Cancelled bool   `json:"cancelled"`
+}
+
+func (h *Handlers) QueueCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !h.bridge.CancelInjection(id) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "injection not queued"})
+		return
+	}
+	writeJSON(w, http.StatusOK, QueueCancelResponse{ID: id, Cancelled: true})
+}
+
+type QueueUpdateRequest struct {
+	Text     *string   `json:"text"`
+	Priority *Priority `json:"priority"`
+	Position *int      `json:"position"`
+}
+
+type QueueUpdateResponse struct {
+	ID       string `json:"id"`
+	Position int    `json:"position"`
+}
+
+func (h *Handlers) QueueUpdate(w http.ResponseWriter, r *http.Request) {
+	var req QueueUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if req.Text != nil && *req.Text == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "text must not be empty"})
+		return
+	}
+
+	update := bridge.QueueUpdate{Text: req.Text}
+	if req.Position != nil {
+		// Positions in the API count from 1.
+		if *req.Position < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "position out of range"})
+			return
+		}
+		pos := *req.Position - 1
+		update.Position = &pos
+	}
+	if req.Priority != nil {
+		level := int(*req.Priority)
+		update.Priority = &level
+	}
+
+	id := r.PathValue("id")
+	pos, err := h.bridge.UpdateInjection(id, update)
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, QueueUpdateResponse{ID: id, Position: pos + 1})
+	case bridge.ErrNotQueued:
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "injection not queued"})
+	case bridge.ErrInvalidPosition:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "position out of range"})
+	case bridge.ErrInvalidPriority:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: priorityRangeError})
+	case bridge.ErrNotDue:
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "injection is scheduled for later"})
+	case bridge.ErrInSequence:
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "injection is part of a sequence"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+	}
+}
+
+type ScreenCursor struct {
+	X       int  `json:"x"`
+	Y       int  `json:"y"`
+	Visible bool `json:"visible"`
+}
+
+type ScreenResponse struct {
+	Cols      int          `json:"cols"`
+	Rows      int          `json:"rows"`
+	Cursor    ScreenCursor `json:"cursor"`
+	AltScreen bool         `json:"alt_screen"`
+	Title     string       `json:"title,omitempty"`
+	Lines     []string     `json:"lines"`
+	HTML      string       `json:"html,omitempty"`
+}
+
+func (h *Handlers) Screen(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "text", "ansi", "html":
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "format must be text, ansi or html"})
+		return
+	}
+
+	snap := h.bridge.Screen().Snapshot()
+	resp := ScreenResponse{
+		Cols: snap.Cols,
+		Rows: snap.Rows,
+		Cursor: ScreenCursor{
+			X:       snap.CursorX,
+			Y:       snap.CursorY,
+			Visible: snap.CursorVisible,
+		},
+		AltScreen: snap.AltScreen,
+		Title:     snap.Title,
+	}
+
+	switch format {
+	case "ansi":
+		resp.Lines = snap.ANSILines()
+	case "html":
+		resp.Lines = snap.Lines()
+		resp.HTML = snap.HTML()
+	default:
+		resp.Lines = snap.Lines()
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type OutputResponse struct {
+	Data      string `json:"data"`
+	Offset    int64  `json:"offset"`
+	Next      int64  `json:"next"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	Truncated bool   `json:"truncated"`
+}
+
+func (h *Handlers) Output(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	since := int64(-1)
+	if v := query.Get("since"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "since must be a non-negative integer"})
+			return
+		}
+		since = n
+	}
+
+	limit := defaultOutputLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "limit must be a positive integer"})
+			return
+		}
+		limit = n
+	}
+
+	format := query.Get("format")
+	if format != "" && format != "raw" && format != "text" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "format must be raw or text"})
+		return
+	}
+
+	chunk := h.bridge.Output().Read(since, limit)
+	data := string(chunk.Data)
+	if format == "text" {
+		data = bridge.StripANSI(data)
+	}
+
+	writeJSON(w, http.StatusOK, OutputResponse{
+		Data:      data,
+		Offset:    chunk.Offset,
+		Next:      chunk.Next,
+		Start:     chunk.Start,
+		End:       chunk.End,
+		Truncated: chunk.Truncated,
+	})
+}
+
+// Events streams bridge events as Server-Sent Events. Clients resume with
+// the Last-Event-ID header (or last_event_id query parameter) and may limit
+// the stream with types=state,injection_queued,...
+func (h *Handlers) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "streaming not supported"})
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var since uint64
+	if lastID != "" {
+		n, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid Last-Event-ID"})
+			return
+		}
+		since = n
+	}
+
+	var types []bridge.EventType
+	if v := r.URL.Query().Get("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			types = append(types, bridge.EventType(strings.TrimSpace(t)))
+		}
+	}
+
+	replay, sub := h.bridge.Events().Subscribe(since, types...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(ev bridge.Event) bool {
+		return writeEvent(w, ev) == nil
+	}
+
+	for _, ev := range replay {
+		if !send(ev) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case ev, ok := <-sub.C:
+			if !ok || !send(ev) {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			for {
+				select {
+				case ev, ok := <-sub.C:
+					if !ok || !send(ev) {
+						flusher.Flush()
+						return
+					}
+				default:
+					flusher.Flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev bridge.Event) error {
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+	return err
+}
+
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/MobAI-App/aibridge/internal/bridge"
 )
 
 func TestHealthHandler(t *testing.T) {
@@ -88,3 +92,568 @@ func TestInjectHandlerEmptyText(t *testing.T) {
 
 	h.Inject(w, req)
 }
+
+func newTestBridge(t *testing.T) *bridge.Bridge {
+	t.Helper()
+	b, err := bridge.New("true", nil, bridge.Options{ScrollbackSize: 1024})
+	if err != nil {
+		t.Fatalf("bridge.New failed: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+	return b
+}
+
+// startTestBridge returns a headless bridge running command, so handlers get
+// past the child check. The child must exit on end of input, which it is
+// sent when the test ends.
+func startTestBridge(t *testing.T, opts bridge.Options, command string, args ...string) *bridge.Bridge {
+	t.Helper()
+	opts.ScrollbackSize = 1024
+	opts.Headless, opts.Cols, opts.Rows = true, 80, 24
+	opts.DiscardOutput = true
+	b, err := bridge.New(command, args, opts)
+	if err != nil {
+		t.Fatalf("bridge.New failed: %v", err)
+	}
+	if err := b.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = b.WriteInput([]byte("\r\x04"))
+		_ = b.Wait()
+		_ = b.Close()
+	})
+	return b
+}
+
+// post sends body to handler and decodes the JSON response into resp.
+func post(t *testing.T, handler http.HandlerFunc, target, body string, resp any) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", target, strings.NewReader(body)))
+	if resp != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+			t.Fatalf("POST %s: failed to parse %q: %v", target, w.Body.String(), err)
+		}
+	}
+	return w
+}
+
+func TestInjectHandlerPosition(t *testing.T) {
+	b := startTestBridge(t, bridge.Options{}, "cat")
+	b.Pause()
+	h := NewHandlers(b)
+
+	var first, urgent, second InjectResponse
+	post(t, h.Inject, "/inject", `{"text": "first"}`, &first)
+	post(t, h.Inject, "/inject", `{"text": "second"}`, &second)
+	w := post(t, h.Inject, "/inject", `{"text": "urgent", "priority": 9}`, &urgent)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if first.Position != 1 || second.Position != 2 {
+		t.Errorf("positions = %d, %d, want 1, 2", first.Position, second.Position)
+	}
+	if urgent.Position != 1 {
+		t.Errorf("urgent position = %d, want 1 ahead of the queue", urgent.Position)
+	}
+}
+
+func TestScreenHandler(t *testing.T) {
+	b := newTestBridge(t)
+	_, _ = b.Screen().Write([]byte("\x1b[32mhello\x1b[0m\r\n> "))
+	h := NewHandlers(b)
+
+	req := httptest.NewRequest("GET", "/screen", nil)
+	w := httptest.NewRecorder()
+	h.Screen(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var resp ScreenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Cols != 80 || resp.Rows != 24 || len(resp.Lines) != 24 {
+		t.Errorf("Size = %dx%d with %d lines, want 80x24 with 24 lines", resp.Cols, resp.Rows, len(resp.Lines))
+	}
+	if resp.Lines[0] != "hello" || resp.Lines[1] != ">" {
+		t.Errorf("Lines = %q, want hello and prompt", resp.Lines[:2])
+	}
+	if resp.Cursor.X != 2 || resp.Cursor.Y != 1 {
+		t.Errorf("Cursor = %+v, want (2, 1)", resp.Cursor)
+	}
+	if resp.HTML != "" {
+		t.Error("HTML should be omitted for text format")
+	}
+}
+
+func TestScreenHandlerFormats(t *testing.T) {
+	h := NewHandlers(newTestBridge(t))
+
+	req := httptest.NewRequest("GET", "/screen?format=html", nil)
+	w := httptest.NewRecorder()
+	h.Screen(w, req)
+
+	var resp ScreenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.HTML == "" {
+		t.Error("HTML should be set for html format")
+	}
+
+	req = httptest.NewRequest("GET", "/screen?format=pdf", nil)
+	w = httptest.NewRecorder()
+	h.Screen(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestOutputHandler(t *testing.T) {
+	b := newTestBridge(t)
+	_, _ = b.Output().Write([]byte("\x1b[1mhello\x1b[0m world"))
+	h := NewHandlers(b)
+
+	req := httptest.NewRequest("GET", "/output?since=0&format=text", nil)
+	w := httptest.NewRecorder()
+	h.Output(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var resp OutputResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if resp.Data != "hello world" {
+		t.Errorf("Data = %q, want %q", resp.Data, "hello world")
+	}
+	if resp.Next != resp.End || resp.Offset != 0 {
+		t.Errorf("Offset = %d, Next = %d, End = %d, want 0 and next == end", resp.Offset, resp.Next, resp.End)
+	}
+
+	req = httptest.NewRequest("GET", "/output?since=-5", nil)
+	w = httptest.NewRecorder()
+	h.Output(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestEventsHandlerReplay(t *testing.T) {
+	b := newTestBridge(t)
+	b.Events().Publish(bridge.EventState, bridge.StateEvent{Idle: false})
+	b.Events().Publish(bridge.EventOutput, bridge.OutputEvent{Data: "hi"})
+	b.Events().Publish(bridge.EventState, bridge.StateEvent{Idle: true})
+
+	h := NewHandlers(b)
+	h.Close()
+
+	req := httptest.NewRequest("GET", "/events?types=state", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	w := httptest.NewRecorder()
+	h.Events(w, req)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want %q", ct, "text/event-stream")
+	}
+
+	want := "id: 3\nevent: state\ndata: {\"idle\":true}\n\n"
+	if w.Body.String() != want {
+		t.Errorf("Body = %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestInjectionStatusHandler(t *testing.T) {
+	b := newTestBridge(t)
+	inj, err := b.Enqueue("hello", bridge.EnqueueOptions{Priority: bridge.MaxPriority})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	b.ClearQueue()
+	h := NewHandlers(b)
+
+	req := httptest.NewRequest("GET", "/inject/"+inj.ID, nil)
+	req.SetPathValue("id", inj.ID)
+	w := httptest.NewRecorder()
+	h.InjectionStatus(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var resp InjectionStatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.ID != inj.ID || resp.State != "cancelled" || resp.Priority != bridge.MaxPriority {
+		t.Errorf("Response = %+v, want cancelled priority injection %s", resp, inj.ID)
+	}
+	if len(resp.History) != 2 || resp.History[0].State != "queued" {
+		t.Errorf("History = %+v, want queued then cancelled", resp.History)
+	}
+
+	req = httptest.NewRequest("GET", "/inject/missing", nil)
+	req.SetPathValue("id", "missing")
+	w = httptest.NewRecorder()
+	h.InjectionStatus(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestQueueItemHandlers(t *testing.T) {
+	b := newTestBridge(t)
+	first, _ := b.Enqueue("first", bridge.EnqueueOptions{})
+	second, _ := b.Enqueue("second", bridge.EnqueueOptions{Sync: true})
+	h := NewHandlers(b)
+
+	req := httptest.NewRequest("PATCH", "/queue/"+second.ID, bytes.NewBufferString(`{"text": "edited", "position": 1}`))
+	req.SetPathValue("id", second.ID)
+	w := httptest.NewRecorder()
+	h.QueueUpdate(w, req)
+
+	var upd QueueUpdateResponse
+	if err := json.NewDecoder(w.Body).Decode(&upd); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusOK || upd.Position != 1 {
+		t.Errorf("PATCH = %d %+v, want 200 at position 1", w.Code, upd)
+	}
+	if items := b.Queue().Items(); items[0].Text != "edited" || items[1].ID != first.ID {
+		t.Errorf("queue = %v, want edited item first", items)
+	}
+
+	req = httptest.NewRequest("PATCH", "/queue/"+first.ID, bytes.NewBufferString(`{"position": 0}`))
+	req.SetPathValue("id", first.ID)
+	w = httptest.NewRecorder()
+	h.QueueUpdate(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH to position 0 = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	req = httptest.NewRequest("DELETE", "/queue/"+second.ID, nil)
+	req.SetPathValue("id", second.ID)
+	w = httptest.NewRecorder()
+	h.QueueCancel(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("DELETE status = %d, want %d", w.Code, http.StatusOK)
+	}
+	<-second.SyncChan
+
+	w = httptest.NewRecorder()
+	h.QueueCancel(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second DELETE status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestQueueListHandler(t *testing.T) {
+	b := newTestBridge(t)
+	long := strings.Repeat("x", 100)
+	_, _ = b.Enqueue("first line\nsecond line", bridge.EnqueueOptions{Source: "mobai"})
+	_, _ = b.Enqueue(long, bridge.EnqueueOptions{Priority: 5})
+	h := NewHandlers(b)
+
+	req := httptest.NewRequest("GET", "/queue", nil)
+	w := httptest.NewRecorder()
+	h.QueueList(w, req)
+
+	var resp QueueListResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Items) != 2 {
+		t.Fatalf("len(Items) = %d, want 2", len(resp.Items))
+	}
+	if it := resp.Items[0]; it.Position != 1 || it.Priority != 5 || it.Preview != strings.Repeat("x", 80)+"…" || it.Text != "" {
+		t.Errorf("Items[0] = %+v, want truncated priority item without text", it)
+	}
+	if it := resp.Items[1]; it.Source != "mobai" || it.Preview != "first line…" {
+		t.Errorf("Items[1] = %+v, want first line preview from mobai", it)
+	}
+
+	req = httptest.NewRequest("GET", "/queue?full=true", nil)
+	w = httptest.NewRecorder()
+	h.QueueList(w, req)
+	resp = QueueListResponse{}
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Items[0].Text != long {
+		t.Errorf("Items[0].Text = %q, want full text", resp.Items[0].Text)
+	}
+}
+
+func TestPriorityUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Priority
+	}{
+		{`true`, bridge.MaxPriority},
+		{`false`, bridge.MinPriority},
+		{`4`, 4},
+	}
+	for _, tt := range tests {
+		var p Priority
+		if err := json.Unmarshal([]byte(tt.input), &p); err != nil {
+			t.Errorf("Unmarshal(%s) failed: %v", tt.input, err)
+			continue
+		}
+		if p != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.input, p, tt.want)
+		}
+	}
+
+	var p Priority
+	if err := json.Unmarshal([]byte(`"high"`), &p); err == nil {
+		t.Error("Unmarshal of a string should fail")
+	}
+}
+
+func TestDurationUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{`30`, 30 * time.Second},
+		{`1.5`, 1500 * time.Millisecond},
+		{`"2m"`, 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		var d Duration
+		if err := json.Unmarshal([]byte(tt.input), &d); err != nil {
+			t.Errorf("Unmarshal(%s) failed: %v", tt.input, err)
+			continue
+		}
+		if time.Duration(d) != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.input, time.Duration(d), tt.want)
+		}
+	}
+
+	var d Duration
+	if err := json.Unmarshal([]byte(`"soon"`), &d); err == nil {
+		t.Error("Unmarshal of an invalid duration should fail")
+	}
+}
+
+func TestScheduleHandlers(t *testing.T) {
+	b := newTestBridge(t)
+	defer b.Schedules().Close()
+	h := NewHandlers(b)
+
+	req := httptest.NewRequest("POST", "/schedules", bytes.NewBufferString(`{"cron": "every day", "text": "hi"}`))
+	w := httptest.NewRecorder()
+	h.ScheduleCreate(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid cron status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	req = httptest.NewRequest("POST", "/schedules", bytes.NewBufferString(`{"cron": "0 9 * * 1-5", "text": "standup", "priority": 3}`))
+	w = httptest.NewRecorder()
+	h.ScheduleCreate(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	var created ScheduleResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if created.Priority != 3 || created.NextRun == nil || created.NextRun.Hour() != 9 {
+		t.Errorf("created = %+v, want priority 3 running at 9:00", created)
+	}
+
+	w = httptest.NewRecorder()
+	h.ScheduleList(w, httptest.NewRequest("GET", "/schedules", nil))
+	var list ScheduleListResponse
+	_ = json.NewDecoder(w.Body).Decode(&list)
+	if len(list.Schedules) != 1 || list.Schedules[0].ID != created.ID {
+		t.Errorf("list = %+v, want the created schedule", list)
+	}
+
+	req = httptest.NewRequest("DELETE", "/schedules/"+created.ID, nil)
+	req.SetPathValue("id", created.ID)
+	w = httptest.NewRecorder()
+	h.ScheduleDelete(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	w = httptest.NewRecorder()
+	h.ScheduleDelete(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("second delete status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestInjectHandlerIdempotencyKey(t *testing.T) {
+	h := NewHandlers(newTestBridge(t))
+	w := post(t, h.Inject, "/inject", `{"text": "hello"}`, nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status without a child = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	b := startTestBridge(t, bridge.Options{}, "cat")
+	b.Pause()
+	h = NewHandlers(b)
+
+	inject := func() (*httptest.ResponseRecorder, InjectResponse) {
+		req := httptest.NewRequest("POST", "/inject", strings.NewReader(`{"text": "run the tests"}`))
+		req.Header.Set("Idempotency-Key", "7f1c2a")
+		w := httptest.NewRecorder()
+		h.Inject(w, req)
+		var resp InjectResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse %q: %v", w.Body.String(), err)
+		}
+		return w, resp
+	}
+
+	w, first := inject()
+	if w.Code != http.StatusOK || first.Duplicate || first.Position != 1 {
+		t.Fatalf("first POST = %d %+v, want 200 queued at position 1", w.Code, first)
+	}
+	w, retry := inject()
+	if w.Code != http.StatusOK {
+		t.Fatalf("retry status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if !retry.Duplicate || retry.ID != first.ID || !retry.Queued || retry.Position != 1 || retry.Status != "queued" {
+		t.Errorf("retry = %+v, want duplicate of %s still queued at position 1", retry, first.ID)
+	}
+	if n := b.Queue().Len(); n != 1 {
+		t.Errorf("Len() = %d, want the retry not queued again", n)
+	}
+
+	b.ClearQueue()
+	if _, retry = inject(); !retry.Duplicate || retry.Queued || retry.Position != 0 || retry.Status != "cancelled" {
+		t.Errorf("retry after clear = %+v, want duplicate reporting cancelled", retry)
+	}
+}
+
+func TestQueuePauseHandlers(t *testing.T) {
+	b := startTestBridge(t, bridge.Options{}, "cat")
+	h := NewHandlers(b)
+
+	status := func() StatusResponse {
+		w := httptest.NewRecorder()
+		h.Status(w, httptest.NewRequest("GET", "/status", nil))
+		var resp StatusResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to parse %q: %v", w.Body.String(), err)
+		}
+		return resp
+	}
+
+	var resp QueuePauseResponse
+	if w := post(t, h.QueuePause, "/queue/pause", "", &resp); w.Code != http.StatusOK || !resp.Paused {
+		t.Errorf("pause = %d %+v, want 200 paused", w.Code, resp)
+	}
+	if s := status(); !s.Paused || !s.ChildRunning {
+		t.Errorf("status = %+v, want paused with the child running", s)
+	}
+
+	post(t, h.Inject, "/inject", `{"text": "held"}`, nil)
+	if s := status(); s.QueueLength != 1 {
+		t.Errorf("queue_length = %d, want the injection held while paused", s.QueueLength)
+	}
+
+	if w := post(t, h.QueueResume, "/queue/resume", "", &resp); w.Code != http.StatusOK || resp.Paused {
+		t.Errorf("resume = %d %+v, want 200 not paused", w.Code, resp)
+	}
+	if s := status(); s.Paused {
+		t.Error("status should not report paused after resume")
+	}
+}
+
+func TestInjectBatchHandler(t *testing.T) {
+	b := startTestBridge(t, bridge.Options{}, "cat")
+	b.Pause()
+	h := NewHandlers(b)
+
+	for _, body := range []string{`{"items": []}`, `{"items": [{"text": "a"}, {"text": ""}]}`, `{"items": [{"text": "a"}], "priority": 10}`} {
+		var resp ErrorResponse
+		if w := post(t, h.InjectBatch, "/inject/batch", body, &resp); w.Code != http.StatusBadRequest || resp.Error == "" {
+			t.Errorf("POST %s = %d %+v, want 400 with an error", body, w.Code, resp)
+		}
+	}
+
+	post(t, h.Inject, "/inject", `{"text": "before"}`, nil)
+	var resp InjectBatchResponse
+	w := post(t, h.InjectBatch, "/inject/batch", `{"items": [{"text": "first read X"}, {"text": "now do Y"}], "source": "mobai"}`, &resp)
+	if w.Code != http.StatusOK || !resp.Queued || resp.Position != 2 || len(resp.IDs) != 2 {
+		t.Fatalf("POST = %d %+v, want 200 with two IDs queued at position 2", w.Code, resp)
+	}
+	items := b.Queue().Snapshot()
+	if len(items) != 3 || items[1].ID != resp.IDs[0] || items[2].ID != resp.IDs[1] {
+		t.Fatalf("queue = %+v, want the sequence after the earlier item", items)
+	}
+	if items[1].Sequence == "" || items[1].Sequence != items[2].Sequence || items[1].Source != "mobai" {
+		t.Errorf("queue = %+v, want both items in one sequence from mobai", items)
+	}
+}
+
+func TestKeysHandler(t *testing.T) {
+	h := NewHandlers(newTestBridge(t))
+	if w := post(t, h.Keys, "/keys", `{"keys": ["esc"]}`, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status without a child = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	b := startTestBridge(t, bridge.Options{}, "cat")
+	b.Pause()
+	h = NewHandlers(b)
+
+	for _, body := range []string{`{"keys": []}`, `{"keys": ["hyper-x"]}`, `{"keys": ["esc"], "queue": true, "priority": 10}`} {
+		var resp ErrorResponse
+		if w := post(t, h.Keys, "/keys", body, &resp); w.Code != http.StatusBadRequest || resp.Error == "" {
+			t.Errorf("POST %s = %d %+v, want 400 with an error", body, w.Code, resp)
+		}
+	}
+
+	var sent KeysResponse
+	if w := post(t, h.Keys, "/keys", `{"keys": ["down", "enter"]}`, &sent); w.Code != http.StatusOK || !sent.Sent {
+		t.Errorf("POST = %d %+v, want 200 sent", w.Code, sent)
+	}
+	if n := b.Queue().Len(); n != 0 {
+		t.Errorf("Len() = %d, want keys sent right away to bypass the queue", n)
+	}
+
+	var queued InjectResponse
+	w := post(t, h.Keys, "/keys", `{"keys": ["shift-tab"], "queue": true, "source": "mobai"}`, &queued)
+	if w.Code != http.StatusOK || !queued.Queued || queued.Position != 1 || queued.ID == "" {
+		t.Fatalf("queued POST = %d %+v, want 200 queued at position 1", w.Code, queued)
+	}
+	if items := b.Queue().Snapshot(); len(items) != 1 || items[0].ID != queued.ID || len(items[0].Keys) != 1 || items[0].Source != "mobai" {
+		t.Errorf("queue = %+v, want the shift-tab injection from mobai", items)
+	}
+}
+
+func TestInterruptHandler(t *testing.T) {
+	h := NewHandlers(newTestBridge(t))
+	if w := post(t, h.Interrupt, "/interrupt", "", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status without a child = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	h = NewHandlers(startTestBridge(t, bridge.Options{}, "cat"))
+	var resp InterruptResponse
+	if w := post(t, h.Interrupt, "/interrupt", "", &resp); w.Code != http.StatusOK || resp.Interrupted || !resp.Idle {
+		t.Errorf("idle POST = %d %+v, want 200 idle and nothing interrupted", w.Code, resp)
+	}
+
+	// The child stays busy until it reads a key, then clears its screen.
+	b := startTestBridge(t, bridge.Options{BusyPattern: "working", InterruptKey: "esc"},
+		"sh", "-c", `stty raw -echo; echo working; dd bs=1 count=1 >/dev/null 2>&1; printf '\033[2J\033[Hdone'; exec dd bs=1 count=1 2>/dev/null`)
+	deadline := time.Now().Add(3 * time.Second)
+	for b.IsIdle() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the tool to go busy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	h = NewHandlers(b)
+	resp = InterruptResponse{}
+	if w := post(t, h.Interrupt, "/interrupt", "", &resp); w.Code != http.StatusOK || !resp.Interrupted || !resp.Idle {
+		t.Errorf("busy POST = %d %+v, want 200 interrupted and idle", w.Code, resp)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/MobAI-App/aibridge/internal/bridge"
+)
+
+type KeysRequest struct {
+	Keys []string `json:"keys"`
+	// Queue sends the keys through the injection queue instead of typing
+	// them right away.
+	Queue    bool     `json:"queue,omitempty"`
+	Priority Priority `json:"priority"`
+	Source   string   `json:"source,omitempty"`
+}
+
+type KeysResponse struct {
+	Sent bool `json:"sent"`
+}
+
+type InterruptResponse struct {
+	Interrupted bool `json:"interrupted"`
+	Idle        bool `json:"idle"`
+}
+
+func (h *Handlers) Keys(w http.ResponseWriter, r *http.Request) {
+	if !h.bridge.IsChildRunning() {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "child process not running"})
+		return
+	}
+
+	var req KeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if err := bridge.ValidateKeys(req.Keys); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if !req.Queue {
+		if err := h.bridge.SendKeys(req.Keys); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, KeysResponse{Sent: true})
+		return
+	}
+
+	inj, err := h.bridge.EnqueueKeys(req.Keys, bridge.EnqueueOptions{
+		Priority: int(req.Priority),
+		Source:   req.Source,
+	})
+	switch err {
+	case nil:
+	case bridge.ErrQueueFull:
+		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "queue is full"})
+		return
+	case bridge.ErrInvalidPriority:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: priorityRangeError})
+		return
+	default:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := InjectResponse{ID: inj.ID, Queued: true}
+	if pos, ok := h.bridge.Queue().Position(inj.ID); ok {
+		resp.Position = pos + 1
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handlers) Interrupt(w http.ResponseWriter, r *http.Request) {
+	if !h.bridge.IsChildRunning() {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "child process not running"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), interruptTimeout)
+	defer cancel()
+
+	interrupted, err := h.bridge.Interrupt(ctx)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, InterruptResponse{
+		Interrupted: interrupted,
+		Idle:        h.bridge.IsIdle(),
+	})
+}
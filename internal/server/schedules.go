@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MobAI-App/aibridge/internal/bridge"
+)
+
+type ScheduleRequest struct {
+	Cron     string   `json:"cron"`
+	Text     string   `json:"text"`
+	Priority Priority `json:"priority"`
+	Source   string   `json:"source,omitempty"`
+}
+
+type ScheduleResponse struct {
+	ID        string     `json:"id"`
+	Cron      string     `json:"cron"`
+	Text      string     `json:"text"`
+	Priority  int        `json:"priority"`
+	Source    string     `json:"source,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastID    string     `json:"last_injection_id,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type ScheduleListResponse struct {
+	Schedules []ScheduleResponse `json:"schedules"`
+}
+
+func (h *Handlers) ScheduleList(w http.ResponseWriter, r *http.Request) {
+	list := h.bridge.Schedules().List()
+	resp := ScheduleListResponse{Schedules: make([]ScheduleResponse, len(list))}
+	for i, s := range list {
+		resp.Schedules[i] = scheduleResponse(s)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handlers) ScheduleCreate(w http.ResponseWriter, r *http.Request) {
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON"})
+		return
+	}
+	if req.Text == "" || req.Cron == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "cron and text are required"})
+		return
+	}
+
+	s, err := h.bridge.Schedules().Add(req.Cron, req.Text, int(req.Priority), req.Source)
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusCreated, scheduleResponse(s))
+	case bridge.ErrInvalidPriority:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: priorityRangeError})
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	}
+}
+
+func (h *Handlers) ScheduleGet(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.bridge.Schedules().Get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "schedule not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, scheduleResponse(s))
+}
+
+func (h *Handlers) ScheduleDelete(w http.ResponseWriter, r *http.Request) {
+	if !h.bridge.Schedules().Remove(r.PathValue("id")) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "schedule not found"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func scheduleResponse(s bridge.Schedule) ScheduleResponse {
+	resp := ScheduleResponse{
+		ID:        s.ID,
+		Cron:      s.Spec,
+		Text:      s.Text,
+		Priority:  s.Priority,
+		Source:    s.Source,
+		CreatedAt: s.CreatedAt,
+		LastID:    s.LastID,
+		LastError: s.LastError,
+	}
+	if !s.Next.IsZero() {
+		resp.NextRun = &s.Next
+	}
+	if !s.LastRun.IsZero() {
+		resp.LastRun = &s.LastRun
+	}
+	return resp
+}
@@ -23,30 +23,68 @@ func New(b *bridge.Bridge, host string, port int, verbose bool) *Server {
 	mux.HandleFunc("GET /health", handlers.Health)
 	mux.HandleFunc("GET /status", handlers.Status)
 	mux.HandleFunc("POST /inject", handlers.Inject)
+	mux.HandleFunc("POST /inject/batch", handlers.InjectBatch)
+	mux.HandleFunc("GET /inject/{id}", handlers.InjectionStatus)
+	mux.HandleFunc("POST /keys", handlers.Keys)
+	mux.HandleFunc("POST /interrupt", handlers.Interrupt)
+	mux.HandleFunc("GET /queue", handlers.QueueList)
 	mux.HandleFunc("DELETE /queue", handlers.QueueClear)
+	mux.HandleFunc("POST /queue/pause", handlers.QueuePause)
+	mux.HandleFunc("POST /queue/resume", handlers.QueueResume)
+	mux.HandleFunc("DELETE /queue/{id}", handlers.QueueCancel)
+	mux.HandleFunc("PATCH /queue/{id}", handlers.QueueUpdate)
+	mux.HandleFunc("GET /schedules", handlers.ScheduleList)
+	mux.HandleFunc("POST /schedules", handlers.ScheduleCreate)
+	mux.HandleFunc("GET /schedules/{id}", handlers.ScheduleGet)
+	mux.HandleFunc("DELETE /schedules/{id}", handlers.ScheduleDelete)
+	mux.HandleFunc("GET /screen", handlers.Screen)
+	mux.HandleFunc("GET /output", handlers.Output)
+	mux.HandleFunc("GET /events", handlers.Events)
+	mux.HandleFunc("GET /attach", handlers.Attach)
 	mux.HandleFunc("OPTIONS /", handlePreflight)
 	mux.HandleFunc("OPTIONS /health", handlePreflight)
 	mux.HandleFunc("OPTIONS /status", handlePreflight)
 	mux.HandleFunc("OPTIONS /inject", handlePreflight)
+	mux.HandleFunc("OPTIONS /inject/batch", handlePreflight)
+	mux.HandleFunc("OPTIONS /inject/{id}", handlePreflight)
+	mux.HandleFunc("OPTIONS /keys", handlePreflight)
+	mux.HandleFunc("OPTIONS /interrupt", handlePreflight)
 	mux.HandleFunc("OPTIONS /queue", handlePreflight)
+	mux.HandleFunc("OPTIONS /queue/{id}", handlePreflight)
+	mux.HandleFunc("OPTIONS /queue/pause", handlePreflight)
+	mux.HandleFunc("OPTIONS /queue/resume", handlePreflight)
+	mux.HandleFunc("OPTIONS /schedules", handlePreflight)
+	mux.HandleFunc("OPTIONS /schedules/{id}", handlePreflight)
+	mux.HandleFunc("OPTIONS /screen", handlePreflight)
+	mux.HandleFunc("OPTIONS /output", handlePreflight)
+	mux.HandleFunc("OPTIONS /events", handlePreflight)
 
 	addr := fmt.Sprintf("%s:%d", host, port)
 
+	httpServer := &http.Server{
+		Addr:    addr,
+		Handler: cors(mux),
+	}
+	httpServer.RegisterOnShutdown(handlers.Close)
+
 	return &Server{
-		httpServer: &http.Server{
-			Addr:    addr,
-			Handler: cors(mux),
-		},
-		handlers: handlers,
-		verbose:  verbose,
+		httpServer: httpServer,
+		handlers:   handlers,
+		verbose:    verbose,
 	}
 }
 
+// AllowOrigins lets web pages from the given origins, such as
+// "https://app.example.com", attach to the session. "*" allows any origin.
+func (s *Server) AllowOrigins(origins []string) {
+	s.handlers.allowedOrigins = origins
+}
+
 func cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID, Idempotency-Key")
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,206 @@
+package vterm
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// ANSILines renders each row with SGR sequences reproducing its colors and
+// attributes. Every row that changes attributes ends with a reset so rows
+// can be printed independently.
+func (s Snapshot) ANSILines() []string {
+	lines := make([]string, len(s.Cells))
+	var sb strings.Builder
+	for y, row := range s.Cells {
+		sb.Reset()
+		cur := Attr{}
+		for _, c := range trimRow(row) {
+			if c.Cont {
+				continue
+			}
+			if c.Attr != cur {
+				sb.WriteString(sgrSequence(c.Attr))
+				cur = c.Attr
+			}
+			sb.WriteRune(c.Char)
+		}
+		if cur != (Attr{}) {
+			sb.WriteString("\x1b[0m")
+		}
+		lines[y] = sb.String()
+	}
+	return lines
+}
+
+// Redraw returns a byte sequence that reproduces the screen, cursor and
+// input modes on a blank terminal of the same size.
+func (s Snapshot) Redraw() []byte {
+	var sb strings.Builder
+	sb.WriteString("\x1b[0m\x1b[H\x1b[2J")
+	sb.WriteString(strings.Join(s.ANSILines(), "\r\n"))
+	fmt.Fprintf(&sb, "\x1b[%d;%dH", s.CursorY+1, s.CursorX+1)
+	if !s.CursorVisible {
+		sb.WriteString("\x1b[?25l")
+	}
+	if s.AppCursorKeys {
+		sb.WriteString("\x1b[?1h")
+	}
+	if s.BracketedPaste {
+		sb.WriteString("\x1b[?2004h")
+	}
+	return []byte(sb.String())
+}
+
+// HTML renders the screen as a <pre> block with inline styles.
+func (s Snapshot) HTML() string {
+	var sb strings.Builder
+	sb.WriteString(`<pre class="aibridge-screen">`)
+	for y, row := range s.Cells {
+		if y > 0 {
+			sb.WriteByte('\n')
+		}
+		cur := Attr{}
+		open := false
+		for _, c := range trimRow(row) {
+			if c.Cont {
+				continue
+			}
+			if c.Attr != cur {
+				if open {
+					sb.WriteString("</span>")
+					open = false
+				}
+				if style := cssStyle(c.Attr); style != "" {
+					sb.WriteString(`<span style="` + style + `">`)
+					open = true
+				}
+				cur = c.Attr
+			}
+			sb.WriteString(html.EscapeString(string(c.Char)))
+		}
+		if open {
+			sb.WriteString("</span>")
+		}
+	}
+	sb.WriteString("</pre>")
+	return sb.String()
+}
+
+func trimRow(row []Cell) []Cell {
+	end := len(row)
+	for end > 0 && (row[end-1].Char == ' ' || row[end-1].Cont) && row[end-1].Attr == (Attr{}) {
+		end--
+	}
+	return row[:end]
+}
+
+func sgrSequence(a Attr) string {
+	params := []string{"0"}
+	flags := []struct {
+		flag Flags
+		code string
+	}{
+		{Bold, "1"}, {Dim, "2"}, {Italic, "3"}, {Underline, "4"},
+		{Blink, "5"}, {Reverse, "7"}, {Hidden, "8"}, {Strike, "9"},
+	}
+	for _, f := range flags {
+		if a.Flags&f.flag != 0 {
+			params = append(params, f.code)
+		}
+	}
+	params = append(params, sgrColor(a.FG, 30)...)
+	params = append(params, sgrColor(a.BG, 40)...)
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+func sgrColor(c Color, base int) []string {
+	if i, ok := c.Index(); ok {
+		switch {
+		case i < 8:
+			return []string{strconv.Itoa(base + int(i))}
+		case i < 16:
+			return []string{strconv.Itoa(base + 60 + int(i) - 8)}
+		default:
+			return []string{strconv.Itoa(base + 8), "5", strconv.Itoa(int(i))}
+		}
+	}
+	if r, g, b, ok := c.RGB(); ok {
+		return []string{strconv.Itoa(base + 8), "2", strconv.Itoa(int(r)), strconv.Itoa(int(g)), strconv.Itoa(int(b))}
+	}
+	return nil
+}
+
+const (
+	htmlDefaultFG = "#e5e5e5"
+	htmlDefaultBG = "#000000"
+)
+
+func cssStyle(a Attr) string {
+	fg, bg := cssColor(a.FG), cssColor(a.BG)
+	if a.Flags&Reverse != 0 {
+		if fg == "" {
+			fg = htmlDefaultFG
+		}
+		if bg == "" {
+			bg = htmlDefaultBG
+		}
+		fg, bg = bg, fg
+	}
+
+	var parts []string
+	if fg != "" {
+		parts = append(parts, "color:"+fg)
+	}
+	if bg != "" {
+		parts = append(parts, "background-color:"+bg)
+	}
+	if a.Flags&Bold != 0 {
+		parts = append(parts, "font-weight:bold")
+	}
+	if a.Flags&Dim != 0 {
+		parts = append(parts, "opacity:0.6")
+	}
+	if a.Flags&Italic != 0 {
+		parts = append(parts, "font-style:italic")
+	}
+	switch {
+	case a.Flags&Underline != 0 && a.Flags&Strike != 0:
+		parts = append(parts, "text-decoration:underline line-through")
+	case a.Flags&Underline != 0:
+		parts = append(parts, "text-decoration:underline")
+	case a.Flags&Strike != 0:
+		parts = append(parts, "text-decoration:line-through")
+	}
+	if a.Flags&Hidden != 0 {
+		parts = append(parts, "visibility:hidden")
+	}
+	return strings.Join(parts, ";")
+}
+
+var basePalette = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+func cssColor(c Color) string {
+	if r, g, b, ok := c.RGB(); ok {
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	}
+	i, ok := c.Index()
+	if !ok {
+		return ""
+	}
+	switch {
+	case i < 16:
+		return basePalette[i]
+	case i < 232:
+		steps := [6]uint8{0, 95, 135, 175, 215, 255}
+		n := i - 16
+		return fmt.Sprintf("#%02x%02x%02x", steps[n/36], steps[(n/6)%6], steps[n%6])
+	default:
+		v := 8 + (i-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", v, v, v)
+	}
+}
@@ -0,0 +1,52 @@
+package vterm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestANSILines(t *testing.T) {
+	term := New(20, 2)
+	_, _ = term.Write([]byte("plain\r\n\x1b[1;31mred\x1b[0m ok"))
+
+	lines := term.Snapshot().ANSILines()
+	if lines[0] != "plain" {
+		t.Errorf("line 0 = %q, want %q", lines[0], "plain")
+	}
+	want := "\x1b[0;1;31mred\x1b[0m ok"
+	if lines[1] != want {
+		t.Errorf("line 1 = %q, want %q", lines[1], want)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	term := New(20, 2)
+	_, _ = term.Write([]byte("a<b>\r\n\x1b[38;5;196;4mx\x1b[0m"))
+
+	got := term.Snapshot().HTML()
+	if !strings.HasPrefix(got, `<pre class="aibridge-screen">a&lt;b&gt;`+"\n") {
+		t.Errorf("HTML() = %q, want escaped first line", got)
+	}
+	if !strings.Contains(got, `<span style="color:#ff0000;text-decoration:underline">x</span>`) {
+		t.Errorf("HTML() = %q, want styled span", got)
+	}
+}
+
+func TestCSSColor(t *testing.T) {
+	tests := []struct {
+		c    Color
+		want string
+	}{
+		{DefaultColor, ""},
+		{IndexedColor(1), "#cd0000"},
+		{IndexedColor(21), "#0000ff"},
+		{IndexedColor(244), "#808080"},
+		{RGBColor(1, 2, 3), "#010203"},
+	}
+
+	for _, tt := range tests {
+		if got := cssColor(tt.c); got != tt.want {
+			t.Errorf("cssColor(%x) = %q, want %q", uint32(tt.c), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,928 @@
+// Package vterm implements a small VT100/xterm screen model. It keeps the
+// visible grid, cursor and cell attributes of a terminal fed with the raw
+// output of a child process, so callers can inspect what is rendered on
+// screen rather than the escape sequences used to draw it.
+package vterm
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	DefaultCols = 80
+	DefaultRows = 24
+)
+
+type Color uint32
+
+const (
+	colorIndexed Color = 1 << 24
+	colorRGB     Color = 2 << 24
+)
+
+const DefaultColor Color = 0
+
+func IndexedColor(i uint8) Color {
+	return colorIndexed | Color(i)
+}
+
+func RGBColor(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+func (c Color) IsDefault() bool {
+	return c == DefaultColor
+}
+
+func (c Color) Index() (uint8, bool) {
+	if c&^0xff != colorIndexed {
+		return 0, false
+	}
+	return uint8(c), true
+}
+
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	if c&^0xffffff != colorRGB {
+		return 0, 0, 0, false
+	}
+	return uint8(c >> 16), uint8(c >> 8), uint8(c), true
+}
+
+type Flags uint16
+
+const (
+	Bold Flags = 1 << iota
+	Dim
+	Italic
+	Underline
+	Blink
+	Reverse
+	Hidden
+	Strike
+)
+
+type Attr struct {
+	FG    Color
+	BG    Color
+	Flags Flags
+}
+
+// Cell is one screen position. A wide rune occupies its cell and the one to
+// its right, which is marked as a continuation with Char set to 0.
+type Cell struct {
+	Char rune
+	Attr Attr
+	Cont bool
+}
+
+func blankCell(a Attr) Cell {
+	return Cell{Char: ' ', Attr: Attr{BG: a.BG}}
+}
+
+type cursor struct {
+	x, y     int
+	attr     Attr
+	wrapNext bool
+	origin   bool
+}
+
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeSkip
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateString
+	stateStringEscape
+)
+
+type Terminal struct {
+	mu sync.Mutex
+
+	cols, rows int
+	primary    [][]Cell
+	alternate  [][]Cell
+	grid       [][]Cell
+	altActive  bool
+
+	cur       cursor
+	saved     cursor
+	savedAlt  cursor
+	top       int
+	bottom    int
+	autowrap  bool
+	cursorOn  bool
+	appCursor bool
+	paste     bool
+	title     string
+	lastRune  rune
+
+	state        parserState
+	params       []int
+	private      byte
+	intermediate byte
+	osc          []byte
+	utf8Buf      []byte
+}
+
+func New(cols, rows int) *Terminal {
+	if cols <= 0 {
+		cols = DefaultCols
+	}
+	if rows <= 0 {
+		rows = DefaultRows
+	}
+	t := &Terminal{cols: cols, rows: rows}
+	t.reset()
+	return t
+}
+
+func (t *Terminal) reset() {
+	t.primary = newGrid(t.cols, t.rows)
+	t.alternate = newGrid(t.cols, t.rows)
+	t.grid = t.primary
+	t.altActive = false
+	t.cur = cursor{}
+	t.saved = cursor{}
+	t.savedAlt = cursor{}
+	t.top = 0
+	t.bottom = t.rows - 1
+	t.autowrap = true
+	t.cursorOn = true
+	t.appCursor = false
+	t.paste = false
+	t.state = stateGround
+}
+
+func newGrid(cols, rows int) [][]Cell {
+	g := make([][]Cell, rows)
+	for i := range g {
+		g[i] = newRow(cols, Attr{})
+	}
+	return g
+}
+
+func newRow(cols int, a Attr) []Cell {
+	row := make([]Cell, cols)
+	for i := range row {
+		row[i] = blankCell(a)
+	}
+	return row
+}
+
+// Write feeds raw terminal output into the emulator. It never fails.
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, c := range p {
+		t.feed(c)
+	}
+	return len(p), nil
+}
+
+func (t *Terminal) Resize(cols, rows int) {
+	if cols <= 0 || rows <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if cols == t.cols && rows == t.rows {
+		return
+	}
+
+	shift := 0
+	if t.cur.y >= rows {
+		shift = t.cur.y - rows + 1
+	}
+	t.primary = resizeGrid(t.primary, cols, rows, shift)
+	t.alternate = resizeGrid(t.alternate, cols, rows, shift)
+	if t.altActive {
+		t.grid = t.alternate
+	} else {
+		t.grid = t.primary
+	}
+
+	t.cols, t.rows = cols, rows
+	t.top, t.bottom = 0, rows-1
+	t.cur.y -= shift
+	t.cur.x = clamp(t.cur.x, 0, cols-1)
+	t.cur.wrapNext = false
+	t.saved.x, t.saved.y = clamp(t.saved.x, 0, cols-1), clamp(t.saved.y, 0, rows-1)
+	t.savedAlt.x, t.savedAlt.y = clamp(t.savedAlt.x, 0, cols-1), clamp(t.savedAlt.y, 0, rows-1)
+}
+
+func resizeGrid(old [][]Cell, cols, rows, shift int) [][]Cell {
+	g := make([][]Cell, rows)
+	for y := range g {
+		g[y] = newRow(cols, Attr{})
+		if src := y + shift; src < len(old) {
+			copy(g[y], old[src])
+			if cols < len(old[src]) && g[y][cols-1].Char != 0 && runeWidth(g[y][cols-1].Char) == 2 {
+				g[y][cols-1] = blankCell(Attr{})
+			}
+		}
+	}
+	return g
+}
+
+func (t *Terminal) Size() (cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cols, t.rows
+}
+
+func (t *Terminal) Cursor() (x, y int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cur.x, t.cur.y
+}
+
+func (t *Terminal) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return gridLines(t.grid)
+}
+
+func (t *Terminal) Text() string {
+	return strings.Join(t.Lines(), "\n")
+}
+
+// BracketedPaste reports whether the application enabled bracketed paste
+// mode (DECSET 2004).
+func (t *Terminal) BracketedPaste() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.paste
+}
+
+// AppCursorKeys reports whether the application enabled application cursor
+// key mode (DECCKM), which changes the sequences sent for arrow keys.
+func (t *Terminal) AppCursorKeys() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.appCursor
+}
+
+type Snapshot struct {
+	Cols           int
+	Rows           int
+	CursorX        int
+	CursorY        int
+	CursorVisible  bool
+	AltScreen      bool
+	AppCursorKeys  bool
+	BracketedPaste bool
+	Title          string
+	Cells          [][]Cell
+}
+
+func (t *Terminal) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cells := make([][]Cell, len(t.grid))
+	for y, row := range t.grid {
+		cells[y] = append([]Cell(nil), row...)
+	}
+	return Snapshot{
+		Cols:           t.cols,
+		Rows:           t.rows,
+		CursorX:        t.cur.x,
+		CursorY:        t.cur.y,
+		CursorVisible:  t.cursorOn,
+		AltScreen:      t.altActive,
+		AppCursorKeys:  t.appCursor,
+		BracketedPaste: t.paste,
+		Title:          t.title,
+		Cells:          cells,
+	}
+}
+
+func (s Snapshot) Lines() []string {
+	return gridLines(s.Cells)
+}
+
+func gridLines(g [][]Cell) []string {
+	lines := make([]string, len(g))
+	var sb strings.Builder
+	for y, row := range g {
+		sb.Reset()
+		for _, c := range row {
+			if c.Cont {
+				continue
+			}
+			sb.WriteRune(c.Char)
+		}
+		lines[y] = strings.TrimRight(sb.String(), " ")
+	}
+	return lines
+}
+
+func (t *Terminal) feed(c byte) {
+	switch t.state {
+	case stateGround:
+		switch {
+		case c == 0x1b:
+			t.utf8Buf = t.utf8Buf[:0]
+			t.state = stateEscape
+		case c < 0x20 || c == 0x7f:
+			t.control(c)
+		default:
+			t.feedUTF8(c)
+		}
+
+	case stateEscape:
+		t.escape(c)
+
+	case stateEscapeSkip:
+		t.state = stateGround
+
+	case stateCSI:
+		switch {
+		case c >= '0' && c <= '9':
+			if len(t.params) == 0 {
+				t.params = append(t.params, 0)
+			}
+			i := len(t.params) - 1
+			if t.params[i] < 10000 {
+				t.params[i] = t.params[i]*10 + int(c-'0')
+			}
+		case c == ';' || c == ':':
+			if len(t.params) == 0 {
+				t.params = append(t.params, 0)
+			}
+			t.params = append(t.params, 0)
+		case c >= '<' && c <= '?':
+			t.private = c
+		case c >= 0x20 && c <= 0x2f:
+			t.intermediate = c
+		case c >= 0x40 && c <= 0x7e:
+			t.state = stateGround
+			t.csi(c)
+		case c == 0x1b:
+			t.state = stateEscape
+		case c < 0x20:
+			t.control(c)
+		}
+
+	case stateOSC:
+		switch c {
+		case 0x07:
+			t.endOSC()
+		case 0x1b:
+			t.state = stateOSCEscape
+		default:
+			if len(t.osc) < 4096 {
+				t.osc = append(t.osc, c)
+			}
+		}
+
+	case stateOSCEscape:
+		t.endOSC()
+		if c != '\\' {
+			t.state = stateEscape
+			t.escape(c)
+		}
+
+	case stateString:
+		switch c {
+		case 0x07:
+			t.state = stateGround
+		case 0x1b:
+			t.state = stateStringEscape
+		}
+
+	case stateStringEscape:
+		t.state = stateGround
+		if c != '\\' {
+			t.state = stateEscape
+			t.escape(c)
+		}
+	}
+}
+
+func (t *Terminal) feedUTF8(c byte) {
+	if c < utf8.RuneSelf && len(t.utf8Buf) == 0 {
+		t.print(rune(c))
+		return
+	}
+
+	t.utf8Buf = append(t.utf8Buf, c)
+	if !utf8.FullRune(t.utf8Buf) {
+		return
+	}
+	r, size := utf8.DecodeRune(t.utf8Buf)
+	rest := append([]byte(nil), t.utf8Buf[size:]...)
+	t.utf8Buf = t.utf8Buf[:0]
+	t.print(r)
+	for _, b := range rest {
+		t.feedUTF8(b)
+	}
+}
+
+func (t *Terminal) escape(c byte) {
+	t.state = stateGround
+	switch c {
+	case '[':
+		t.params = t.params[:0]
+		t.private = 0
+		t.intermediate = 0
+		t.state = stateCSI
+	case ']':
+		t.osc = t.osc[:0]
+		t.state = stateOSC
+	case 'P', 'X', '^', '_':
+		t.state = stateString
+	case '(', ')', '*', '+', '-', '.', '/', '#', '%', ' ':
+		t.state = stateEscapeSkip
+	case '7':
+		t.saveCursor()
+	case '8':
+		t.restoreCursor()
+	case 'D':
+		t.index()
+	case 'E':
+		t.cur.x = 0
+		t.index()
+	case 'M':
+		t.reverseIndex()
+	case 'c':
+		t.reset()
+	case 0x1b:
+		t.state = stateEscape
+	}
+}
+
+func (t *Terminal) endOSC() {
+	t.state = stateGround
+	s := string(t.osc)
+	if strings.HasPrefix(s, "0;") || strings.HasPrefix(s, "2;") {
+		t.title = s[2:]
+	}
+}
+
+func (t *Terminal) control(c byte) {
+	switch c {
+	case '\b':
+		if t.cur.x > 0 {
+			t.cur.x--
+		}
+		t.cur.wrapNext = false
+	case '\t':
+		t.cur.x = min((t.cur.x/8+1)*8, t.cols-1)
+		t.cur.wrapNext = false
+	case '\n', '\v', '\f':
+		t.index()
+	case '\r':
+		t.cur.x = 0
+		t.cur.wrapNext = false
+	}
+}
+
+func (t *Terminal) print(r rune) {
+	w := runeWidth(r)
+	if w == 0 {
+		return
+	}
+
+	if t.cur.wrapNext {
+		t.cur.wrapNext = false
+		if t.autowrap {
+			t.cur.x = 0
+			t.index()
+		}
+	}
+
+	if w == 2 && t.cur.x == t.cols-1 {
+		if !t.autowrap || t.cols < 2 {
+			return
+		}
+		t.grid[t.cur.y][t.cur.x] = blankCell(t.cur.attr)
+		t.cur.x = 0
+		t.index()
+	}
+
+	row := t.grid[t.cur.y]
+	t.clearWide(row, t.cur.x)
+	row[t.cur.x] = Cell{Char: r, Attr: t.cur.attr}
+	if w == 2 {
+		t.clearWide(row, t.cur.x+1)
+		row[t.cur.x+1] = Cell{Attr: t.cur.attr, Cont: true}
+	}
+	t.lastRune = r
+
+	if t.cur.x+w >= t.cols {
+		t.cur.x = t.cols - 1
+		t.cur.wrapNext = true
+	} else {
+		t.cur.x += w
+	}
+}
+
+// clearWide blanks the other half of a wide rune before x is overwritten.
+func (t *Terminal) clearWide(row []Cell, x int) {
+	if row[x].Cont && x > 0 {
+		row[x-1] = blankCell(row[x-1].Attr)
+	} else if x+1 < len(row) && row[x+1].Cont {
+		row[x+1] = blankCell(row[x+1].Attr)
+	}
+}
+
+func (t *Terminal) index() {
+	switch {
+	case t.cur.y == t.bottom:
+		t.scrollUp(1)
+	case t.cur.y < t.rows-1:
+		t.cur.y++
+	}
+}
+
+func (t *Terminal) reverseIndex() {
+	switch {
+	case t.cur.y == t.top:
+		t.scrollDown(1)
+	case t.cur.y > 0:
+		t.cur.y--
+	}
+}
+
+func (t *Terminal) scrollUp(n int) {
+	n = min(n, t.bottom-t.top+1)
+	copy(t.grid[t.top:t.bottom+1], t.grid[t.top+n:t.bottom+1])
+	for y := t.bottom - n + 1; y <= t.bottom; y++ {
+		t.grid[y] = newRow(t.cols, t.cur.attr)
+	}
+}
+
+func (t *Terminal) scrollDown(n int) {
+	n = min(n, t.bottom-t.top+1)
+	copy(t.grid[t.top+n:t.bottom+1], t.grid[t.top:t.bottom+1-n])
+	for y := t.top; y < t.top+n; y++ {
+		t.grid[y] = newRow(t.cols, t.cur.attr)
+	}
+}
+
+func (t *Terminal) saveCursor() {
+	t.saved = t.cur
+}
+
+func (t *Terminal) restoreCursor() {
+	t.cur = t.saved
+	t.cur.x = clamp(t.cur.x, 0, t.cols-1)
+	t.cur.y = clamp(t.cur.y, 0, t.rows-1)
+}
+
+func (t *Terminal) param(i, def int) int {
+	if i >= len(t.params) || t.params[i] == 0 {
+		return def
+	}
+	return t.params[i]
+}
+
+func (t *Terminal) csi(final byte) {
+	if t.private != 0 && final != 'h' && final != 'l' {
+		return
+	}
+	if t.intermediate != 0 {
+		return
+	}
+
+	t.cur.wrapNext = false
+
+	switch final {
+	case 'A':
+		t.cur.y = max(t.cur.y-t.param(0, 1), t.minY())
+	case 'B', 'e':
+		t.cur.y = min(t.cur.y+t.param(0, 1), t.maxY())
+	case 'C', 'a':
+		t.cur.x = min(t.cur.x+t.param(0, 1), t.cols-1)
+	case 'D':
+		t.cur.x = max(t.cur.x-t.param(0, 1), 0)
+	case 'E':
+		t.cur.y = min(t.cur.y+t.param(0, 1), t.maxY())
+		t.cur.x = 0
+	case 'F':
+		t.cur.y = max(t.cur.y-t.param(0, 1), t.minY())
+		t.cur.x = 0
+	case 'G', '`':
+		t.cur.x = clamp(t.param(0, 1)-1, 0, t.cols-1)
+	case 'H', 'f':
+		t.moveTo(t.param(1, 1)-1, t.param(0, 1)-1)
+	case 'd':
+		t.moveTo(t.cur.x, t.param(0, 1)-1)
+	case 'J':
+		t.eraseDisplay(t.param(0, 0))
+	case 'K':
+		t.eraseLine(t.param(0, 0))
+	case 'L':
+		t.insertLines(t.param(0, 1))
+	case 'M':
+		t.deleteLines(t.param(0, 1))
+	case '@':
+		t.insertChars(t.param(0, 1))
+	case 'P':
+		t.deleteChars(t.param(0, 1))
+	case 'X':
+		row := t.grid[t.cur.y]
+		for x := t.cur.x; x < min(t.cur.x+t.param(0, 1), t.cols); x++ {
+			row[x] = blankCell(t.cur.attr)
+		}
+	case 'S':
+		t.scrollUp(t.param(0, 1))
+	case 'T':
+		t.scrollDown(t.param(0, 1))
+	case 'b':
+		if t.lastRune != 0 {
+			for i := 0; i < min(t.param(0, 1), t.cols*t.rows); i++ {
+				t.print(t.lastRune)
+			}
+		}
+	case 'm':
+		t.sgr()
+	case 'r':
+		top, bottom := t.param(0, 1)-1, t.param(1, t.rows)-1
+		if top < bottom && bottom < t.rows {
+			t.top, t.bottom = top, bottom
+			t.moveTo(0, 0)
+		}
+	case 's':
+		t.saveCursor()
+	case 'u':
+		t.restoreCursor()
+	case 'h', 'l':
+		t.setModes(final == 'h')
+	}
+}
+
+func (t *Terminal) minY() int {
+	if t.cur.y >= t.top {
+		return t.top
+	}
+	return 0
+}
+
+func (t *Terminal) maxY() int {
+	if t.cur.y <= t.bottom {
+		return t.bottom
+	}
+	return t.rows - 1
+}
+
+func (t *Terminal) moveTo(x, y int) {
+	if t.cur.origin {
+		y += t.top
+		t.cur.y = clamp(y, t.top, t.bottom)
+	} else {
+		t.cur.y = clamp(y, 0, t.rows-1)
+	}
+	t.cur.x = clamp(x, 0, t.cols-1)
+}
+
+func (t *Terminal) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		t.eraseLine(0)
+		for y := t.cur.y + 1; y < t.rows; y++ {
+			t.grid[y] = newRow(t.cols, t.cur.attr)
+		}
+	case 1:
+		t.eraseLine(1)
+		for y := 0; y < t.cur.y; y++ {
+			t.grid[y] = newRow(t.cols, t.cur.attr)
+		}
+	case 2, 3:
+		for y := range t.grid {
+			t.grid[y] = newRow(t.cols, t.cur.attr)
+		}
+	}
+}
+
+func (t *Terminal) eraseLine(mode int) {
+	row := t.grid[t.cur.y]
+	from, to := 0, t.cols
+	switch mode {
+	case 0:
+		from = t.cur.x
+	case 1:
+		to = t.cur.x + 1
+	}
+	for x := from; x < to; x++ {
+		row[x] = blankCell(t.cur.attr)
+	}
+}
+
+func (t *Terminal) insertLines(n int) {
+	if t.cur.y < t.top || t.cur.y > t.bottom {
+		return
+	}
+	top := t.top
+	t.top = t.cur.y
+	t.scrollDown(n)
+	t.top = top
+	t.cur.x = 0
+}
+
+func (t *Terminal) deleteLines(n int) {
+	if t.cur.y < t.top || t.cur.y > t.bottom {
+		return
+	}
+	top := t.top
+	t.top = t.cur.y
+	t.scrollUp(n)
+	t.top = top
+	t.cur.x = 0
+}
+
+func (t *Terminal) insertChars(n int) {
+	row := t.grid[t.cur.y]
+	n = min(n, t.cols-t.cur.x)
+	copy(row[t.cur.x+n:], row[t.cur.x:])
+	for x := t.cur.x; x < t.cur.x+n; x++ {
+		row[x] = blankCell(t.cur.attr)
+	}
+}
+
+func (t *Terminal) deleteChars(n int) {
+	row := t.grid[t.cur.y]
+	n = min(n, t.cols-t.cur.x)
+	copy(row[t.cur.x:], row[t.cur.x+n:])
+	for x := t.cols - n; x < t.cols; x++ {
+		row[x] = blankCell(t.cur.attr)
+	}
+}
+
+func (t *Terminal) setModes(on bool) {
+	params := t.params
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for _, p := range params {
+		if t.private != '?' {
+			continue
+		}
+		switch p {
+		case 1:
+			t.appCursor = on
+		case 6:
+			t.cur.origin = on
+			t.moveTo(0, 0)
+		case 7:
+			t.autowrap = on
+		case 25:
+			t.cursorOn = on
+		case 47, 1047:
+			t.switchScreen(on, false)
+		case 1048:
+			if on {
+				t.saveCursor()
+			} else {
+				t.restoreCursor()
+			}
+		case 1049:
+			t.switchScreen(on, true)
+		case 2004:
+			t.paste = on
+		}
+	}
+}
+
+func (t *Terminal) switchScreen(alt, saveCursor bool) {
+	if alt == t.altActive {
+		return
+	}
+	if alt {
+		if saveCursor {
+			t.savedAlt = t.cur
+		}
+		t.alternate = newGrid(t.cols, t.rows)
+		t.grid = t.alternate
+	} else {
+		t.grid = t.primary
+		if saveCursor {
+			t.cur = t.savedAlt
+		}
+	}
+	t.altActive = alt
+}
+
+func (t *Terminal) sgr() {
+	params := t.params
+	if len(params) == 0 {
+		params = []int{0}
+	}
+
+	a := &t.cur.attr
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			*a = Attr{}
+		case p == 1:
+			a.Flags |= Bold
+		case p == 2:
+			a.Flags |= Dim
+		case p == 3:
+			a.Flags |= Italic
+		case p == 4:
+			a.Flags |= Underline
+		case p == 5 || p == 6:
+			a.Flags |= Blink
+		case p == 7:
+			a.Flags |= Reverse
+		case p == 8:
+			a.Flags |= Hidden
+		case p == 9:
+			a.Flags |= Strike
+		case p == 22:
+			a.Flags &^= Bold | Dim
+		case p == 23:
+			a.Flags &^= Italic
+		case p == 24:
+			a.Flags &^= Underline
+		case p == 25:
+			a.Flags &^= Blink
+		case p == 27:
+			a.Flags &^= Reverse
+		case p == 28:
+			a.Flags &^= Hidden
+		case p == 29:
+			a.Flags &^= Strike
+		case p >= 30 && p <= 37:
+			a.FG = IndexedColor(uint8(p - 30))
+		case p == 38:
+			a.FG, i = extendedColor(params, i, a.FG)
+		case p == 39:
+			a.FG = DefaultColor
+		case p >= 40 && p <= 47:
+			a.BG = IndexedColor(uint8(p - 40))
+		case p == 48:
+			a.BG, i = extendedColor(params, i, a.BG)
+		case p == 49:
+			a.BG = DefaultColor
+		case p >= 90 && p <= 97:
+			a.FG = IndexedColor(uint8(p - 90 + 8))
+		case p >= 100 && p <= 107:
+			a.BG = IndexedColor(uint8(p - 100 + 8))
+		}
+	}
+}
+
+func extendedColor(params []int, i int, cur Color) (Color, int) {
+	if i+1 >= len(params) {
+		return cur, i
+	}
+	switch params[i+1] {
+	case 5:
+		if i+2 < len(params) {
+			return IndexedColor(uint8(params[i+2])), i + 2
+		}
+	case 2:
+		if i+4 < len(params) {
+			return RGBColor(uint8(params[i+2]), uint8(params[i+3]), uint8(params[i+4])), i + 4
+		}
+	}
+	return cur, len(params)
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+// runeWidth returns the number of columns r occupies. It covers combining
+// marks and the common wide ranges (CJK, Hangul, fullwidth forms, emoji)
+// rather than the full Unicode East Asian Width tables.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0:
+		return 0
+	case r >= 0x0300 && r <= 0x036f,
+		r >= 0x200b && r <= 0x200f,
+		r >= 0x20d0 && r <= 0x20ff,
+		r >= 0xfe00 && r <= 0xfe0f:
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0x303e,
+		r >= 0x3041 && r <= 0x33ff,
+		r >= 0x3400 && r <= 0x4dbf,
+		r >= 0x4e00 && r <= 0x9fff,
+		r >= 0xa000 && r <= 0xa4cf,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,185 @@
+package vterm
+
+import "testing"
+
+func TestPrintAndWrap(t *testing.T) {
+	term := New(10, 3)
+	_, _ = term.Write([]byte("hello\r\nworld, wrapped"))
+
+	lines := term.Lines()
+	want := []string{"hello", "world, wra", "pped"}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d = %q, want %q", i, lines[i], w)
+		}
+	}
+
+	x, y := term.Cursor()
+	if x != 4 || y != 2 {
+		t.Errorf("Cursor() = (%d, %d), want (4, 2)", x, y)
+	}
+}
+
+func TestScrollOnLineFeed(t *testing.T) {
+	term := New(10, 2)
+	_, _ = term.Write([]byte("one\r\ntwo\r\nthree"))
+
+	lines := term.Lines()
+	if lines[0] != "two" || lines[1] != "three" {
+		t.Errorf("Lines() = %q, want [two three]", lines)
+	}
+}
+
+func TestCursorMovementRedraw(t *testing.T) {
+	term := New(20, 4)
+	_, _ = term.Write([]byte("✻ Thinking… (esc to interrupt)"))
+	_, _ = term.Write([]byte("\x1b[1;1H\x1b[2K> \x1b[3;5Hdone"))
+
+	lines := term.Lines()
+	if lines[0] != ">" {
+		t.Errorf("line 0 = %q, want %q", lines[0], ">")
+	}
+	if lines[2] != "    done" {
+		t.Errorf("line 2 = %q, want %q", lines[2], "    done")
+	}
+}
+
+func TestEraseDisplay(t *testing.T) {
+	term := New(10, 3)
+	_, _ = term.Write([]byte("aaa\r\nbbb\r\nccc\x1b[2;2H\x1b[J"))
+
+	lines := term.Lines()
+	want := []string{"aaa", "b", ""}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d = %q, want %q", i, lines[i], w)
+		}
+	}
+}
+
+func TestScrollRegion(t *testing.T) {
+	term := New(10, 4)
+	_, _ = term.Write([]byte("header\x1b[2;3r\x1b[2;1Ha\r\nb\r\nc\x1b[4;1Hfooter"))
+
+	lines := term.Lines()
+	want := []string{"header", "b", "c", "footer"}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d = %q, want %q", i, lines[i], w)
+		}
+	}
+}
+
+func TestInsertDeleteChars(t *testing.T) {
+	term := New(10, 1)
+	_, _ = term.Write([]byte("abcdef\x1b[1;2H\x1b[2P"))
+	if got := term.Lines()[0]; got != "adef" {
+		t.Errorf("after DCH = %q, want %q", got, "adef")
+	}
+
+	_, _ = term.Write([]byte("\x1b[3@XYZ"))
+	if got := term.Lines()[0]; got != "aXYZdef" {
+		t.Errorf("after ICH = %q, want %q", got, "aXYZdef")
+	}
+}
+
+func TestAltScreen(t *testing.T) {
+	term := New(10, 2)
+	_, _ = term.Write([]byte("shell$ "))
+	_, _ = term.Write([]byte("\x1b[?1049h\x1b[Hfullscreen"))
+
+	if got := term.Lines()[0]; got != "fullscreen" {
+		t.Errorf("alt screen line = %q, want %q", got, "fullscreen")
+	}
+	if !term.Snapshot().AltScreen {
+		t.Error("Snapshot should report alt screen")
+	}
+
+	_, _ = term.Write([]byte("\x1b[?1049l"))
+	if got := term.Lines()[0]; got != "shell$" {
+		t.Errorf("primary line = %q, want %q", got, "shell$")
+	}
+	if x, _ := term.Cursor(); x != 7 {
+		t.Errorf("cursor x after leaving alt screen = %d, want 7", x)
+	}
+}
+
+func TestSGR(t *testing.T) {
+	term := New(10, 1)
+	_, _ = term.Write([]byte("\x1b[1;31mA\x1b[38;5;200;48;2;1;2;3mB\x1b[0mC"))
+
+	cells := term.Snapshot().Cells[0]
+	if cells[0].Attr.Flags&Bold == 0 {
+		t.Error("A should be bold")
+	}
+	if idx, ok := cells[0].Attr.FG.Index(); !ok || idx != 1 {
+		t.Errorf("A fg = %v, want index 1", cells[0].Attr.FG)
+	}
+	if idx, ok := cells[1].Attr.FG.Index(); !ok || idx != 200 {
+		t.Errorf("B fg = %v, want index 200", cells[1].Attr.FG)
+	}
+	if r, g, b, ok := cells[1].Attr.BG.RGB(); !ok || r != 1 || g != 2 || b != 3 {
+		t.Errorf("B bg = %v, want rgb(1,2,3)", cells[1].Attr.BG)
+	}
+	if cells[2].Attr != (Attr{}) {
+		t.Errorf("C attr = %+v, want default", cells[2].Attr)
+	}
+}
+
+func TestSplitUTF8AndEscape(t *testing.T) {
+	term := New(10, 1)
+	data := []byte("\x1b[32m╭─✻\x1b[0m")
+	for i := range data {
+		_, _ = term.Write(data[i : i+1])
+	}
+
+	if got := term.Lines()[0]; got != "╭─✻" {
+		t.Errorf("line = %q, want %q", got, "╭─✻")
+	}
+}
+
+func TestWideRunes(t *testing.T) {
+	term := New(5, 2)
+	_, _ = term.Write([]byte("a日本語"))
+
+	lines := term.Lines()
+	if lines[0] != "a日本" || lines[1] != "語" {
+		t.Errorf("Lines() = %q, want [a日本 語]", lines)
+	}
+}
+
+func TestModes(t *testing.T) {
+	term := New(10, 1)
+	_, _ = term.Write([]byte("\x1b[?2004h\x1b[?1h\x1b]0;my title\x07"))
+
+	if !term.BracketedPaste() {
+		t.Error("BracketedPaste should be enabled")
+	}
+	if !term.AppCursorKeys() {
+		t.Error("AppCursorKeys should be enabled")
+	}
+	if got := term.Snapshot().Title; got != "my title" {
+		t.Errorf("Title = %q, want %q", got, "my title")
+	}
+
+	_, _ = term.Write([]byte("\x1b[?2004l"))
+	if term.BracketedPaste() {
+		t.Error("BracketedPaste should be disabled")
+	}
+}
+
+func TestResize(t *testing.T) {
+	term := New(10, 3)
+	_, _ = term.Write([]byte("one\r\ntwo\r\nthree"))
+
+	term.Resize(4, 2)
+	cols, rows := term.Size()
+	if cols != 4 || rows != 2 {
+		t.Errorf("Size() = (%d, %d), want (4, 2)", cols, rows)
+	}
+
+	lines := term.Lines()
+	if lines[0] != "two" || lines[1] != "thre" {
+		t.Errorf("Lines() = %q, want [two thre]", lines)
+	}
+}